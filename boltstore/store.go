@@ -0,0 +1,206 @@
+// Package boltstore is a session manager store that keeps the sessions in an embedded bbolt file
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Nested buckets of the bucket of a Store
+var (
+	sessionsBucket = []byte("sessions")
+	expiryBucket   = []byte("expiry")
+)
+
+// Options configures a Store
+type Options struct {
+	// Bucket holding the sessions of a session manager, by default "sessions"
+	Bucket string
+}
+
+// Store is a session manager store that keeps the sessions in an embedded bbolt file
+//   - Each session manager uses its own bucket, holding the sessions and an expiry index
+//   - The session and its expiry index entry are updated in the same transaction
+type Store struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// Verify that Store implements sessionmanager.Store
+var _ sessionmanager.Store = (*Store)(nil)
+
+// New is the constructor for bolt store, the buckets are created if they do not exist
+func New(db *bolt.DB, opts Options) (*Store, error) {
+	if opts.Bucket == "" {
+		opts.Bucket = "sessions"
+	}
+	bs := &Store{db: db, bucket: []byte(opts.Bucket)}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(bs.bucket)
+		if err != nil {
+			return err
+		}
+		if _, err := root.CreateBucketIfNotExists(sessionsBucket); err != nil {
+			return err
+		}
+		_, err = root.CreateBucketIfNotExists(expiryBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating bolt buckets: %w", err)
+	}
+	return bs, nil
+}
+
+// Load a session, expired sessions are reported as not found
+func (bs *Store) Load(ctx context.Context, sessionId string) (*sessionmanager.Session, error) {
+	var record sessionmanager.SessionRecord
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		sessions, _ := bs.buckets(tx)
+		data := sessions.Get([]byte(sessionId))
+		if data == nil {
+			return sessionmanager.ErrSessionNotFound
+		}
+		var err error
+		record, err = sessionmanager.DecodeSessionRecord(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record.IsExpired() {
+		return nil, sessionmanager.ErrSessionNotFound
+	}
+	return record.Session(), nil
+}
+
+// Save a session and its expiry index entry in a single transaction
+//   - It returns ErrVersionConflict if the stored session has other version or
+//     a saved session is no longer stored
+func (bs *Store) Save(ctx context.Context, s *sessionmanager.Session) error {
+	record := sessionmanager.NewSessionRecord(s)
+	saved := record
+	saved.Version++
+	data, err := sessionmanager.EncodeSessionRecord(saved)
+	if err != nil {
+		return fmt.Errorf("encoding session: %w", err)
+	}
+
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		sessions, expiry := bs.buckets(tx)
+		stored, err := bs.stored(sessions, record.ID)
+		if err != nil {
+			return err
+		}
+		if stored == nil && record.Version > 0 && !record.IsExpired() {
+			// The session was deleted since it was loaded or saved, it is not created again
+			return sessionmanager.VersionConflict(record.ID, record.Version)
+		}
+		if stored != nil {
+			if stored.Version != record.Version {
+				return sessionmanager.VersionConflict(record.ID, record.Version)
+			}
+			if err := expiry.Delete(expiryKey(stored.ExpirationTime, record.ID)); err != nil {
+				return err
+			}
+		}
+		if record.IsExpired() {
+			return sessions.Delete([]byte(record.ID))
+		}
+		if err := sessions.Put([]byte(record.ID), data); err != nil {
+			return err
+		}
+		return expiry.Put(expiryKey(record.ExpirationTime, record.ID), nil)
+	})
+	if err != nil {
+		return err
+	}
+	s.Saved(saved.Version)
+	return nil
+}
+
+// Delete a session and its expiry index entry
+func (bs *Store) Delete(ctx context.Context, sessionId string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		sessions, expiry := bs.buckets(tx)
+		if sessions.Get([]byte(sessionId)) == nil {
+			return sessionmanager.ErrSessionNotFound
+		}
+		if err := bs.deleteIndex(sessions, expiry, sessionId); err != nil {
+			return err
+		}
+		return sessions.Delete([]byte(sessionId))
+	})
+}
+
+// DeleteExpired deletes the expired sessions walking the expiry index
+// in order, so only the expired entries are read, returns the number of deleted sessions
+func (bs *Store) DeleteExpired(ctx context.Context) (int, error) {
+	deleted := 0
+	now := expiryKey(time.Now(), "")
+
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		sessions, expiry := bs.buckets(tx)
+
+		// Deleting while iterating makes the cursor skip entries, so collect them first
+		var keys [][]byte
+		c := expiry.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], now) <= 0; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		for _, k := range keys {
+			if err := sessions.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := expiry.Delete(k); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// buckets returns the sessions and expiry buckets of the store
+func (bs *Store) buckets(tx *bolt.Tx) (*bolt.Bucket, *bolt.Bucket) {
+	root := tx.Bucket(bs.bucket)
+	return root.Bucket(sessionsBucket), root.Bucket(expiryBucket)
+}
+
+// deleteIndex deletes the expiry index entry of the stored session if it exists
+func (bs *Store) deleteIndex(sessions, expiry *bolt.Bucket, sessionId string) error {
+	stored, err := bs.stored(sessions, sessionId)
+	if err != nil || stored == nil {
+		return err
+	}
+	return expiry.Delete(expiryKey(stored.ExpirationTime, sessionId))
+}
+
+// stored returns the stored session or nil if it does not exist
+func (bs *Store) stored(sessions *bolt.Bucket, sessionId string) (*sessionmanager.SessionRecord, error) {
+	data := sessions.Get([]byte(sessionId))
+	if data == nil {
+		return nil, nil
+	}
+	stored, err := sessionmanager.DecodeSessionRecord(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding session: %w", err)
+	}
+	return &stored, nil
+}
+
+// expiryKey builds an expiry index key sorted by expiration time
+func expiryKey(expirationTime time.Time, sessionId string) []byte {
+	key := make([]byte, 8, 8+len(sessionId))
+	binary.BigEndian.PutUint64(key, uint64(expirationTime.UnixNano()))
+	return append(key, sessionId...)
+}
@@ -0,0 +1,204 @@
+package boltstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/solrac97gr/session-manager/boltstore"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newStore(t *testing.T, bucket string) (*boltstore.Store, *bolt.DB) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sessions.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := boltstore.New(db, boltstore.Options{Bucket: bucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, db
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	cases := map[string]struct {
+		data       map[string]interface{}
+		expiration time.Duration
+		extend     time.Duration
+		err        error
+	}{
+		"empty": {
+			data:       map[string]interface{}{},
+			expiration: time.Hour,
+		},
+
+		"with data": {
+			data: map[string]interface{}{
+				"user":  "solrac",
+				"age":   20,
+				"roles": []string{"admin"},
+			},
+			expiration: time.Hour,
+		},
+
+		"expiration extended": {
+			data:       map[string]interface{}{"user": "solrac"},
+			expiration: time.Hour,
+			extend:     2 * time.Hour,
+		},
+
+		"expired": {
+			data:       map[string]interface{}{"user": "solrac"},
+			expiration: -time.Minute,
+			err:        sessionmanager.ErrSessionNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, _ := newStore(t, "")
+			ctx := context.Background()
+
+			session := sessionmanager.NewSession(tc.data)
+			expiration := time.Now().Add(tc.expiration)
+			session.SetExpirationTime(expiration)
+			assert.NoError(t, store.Save(ctx, session))
+
+			if tc.extend != 0 {
+				expiration = time.Now().Add(tc.extend)
+				session.SetExpirationTime(expiration)
+				assert.NoError(t, store.Save(ctx, session))
+			}
+
+			loaded, err := store.Load(ctx, session.SessionId())
+			assert.Equal(t, tc.err, err)
+			if tc.err != nil {
+				return
+			}
+			assert.Equal(t, tc.data, loaded.Data)
+			assert.True(t, expiration.Equal(loaded.ExpirationTime))
+
+			// The old expiry index entry is replaced, so sweeping does not delete the session
+			deleted, err := store.DeleteExpired(ctx)
+			assert.NoError(t, err)
+			assert.Zero(t, deleted)
+		})
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	cases := map[string]struct {
+		saved bool
+		err   error
+	}{
+		"saved": {
+			saved: true,
+		},
+
+		"not found": {
+			err: sessionmanager.ErrSessionNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, _ := newStore(t, "")
+			session := sessionmanager.NewSession(nil)
+			if tc.saved {
+				assert.NoError(t, store.Save(context.Background(), session))
+			}
+
+			assert.Equal(t, tc.err, store.Delete(context.Background(), session.SessionId()))
+
+			_, err := store.Load(context.Background(), session.SessionId())
+			assert.Equal(t, sessionmanager.ErrSessionNotFound, err)
+		})
+	}
+}
+
+func TestStore_DeleteExpired(t *testing.T) {
+	cases := map[string]struct {
+		expired int
+		valid   int
+	}{
+		"nothing expired": {
+			valid: 3,
+		},
+
+		"some expired": {
+			expired: 5,
+			valid:   2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, db := newStore(t, "app")
+			ctx := context.Background()
+
+			for i := 0; i < tc.expired+tc.valid; i++ {
+				session := sessionmanager.NewSession(nil)
+				if i < tc.expired {
+					session.SetExpirationTime(time.Now().Add(50 * time.Millisecond))
+				} else {
+					session.SetExpirationTime(time.Now().Add(time.Hour))
+				}
+				assert.NoError(t, store.Save(ctx, session))
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			deleted, err := store.DeleteExpired(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expired, deleted)
+
+			db.View(func(tx *bolt.Tx) error {
+				root := tx.Bucket([]byte("app"))
+				assert.Equal(t, tc.valid, root.Bucket([]byte("sessions")).Stats().KeyN)
+				assert.Equal(t, tc.valid, root.Bucket([]byte("expiry")).Stats().KeyN)
+				return nil
+			})
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		buckets []string
+	}{
+		"default bucket": {
+			buckets: []string{""},
+		},
+
+		"bucket per manager": {
+			buckets: []string{"first", "second"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "sessions.db"), 0o600, nil)
+			assert.NoError(t, err)
+			defer db.Close()
+
+			session := sessionmanager.NewSession(nil)
+			for i, bucket := range tc.buckets {
+				store, err := boltstore.New(db, boltstore.Options{Bucket: bucket})
+				assert.NoError(t, err)
+
+				// Only the first bucket has the session
+				if i == 0 {
+					assert.NoError(t, store.Save(context.Background(), session))
+					continue
+				}
+				_, err = store.Load(context.Background(), session.SessionId())
+				assert.Equal(t, sessionmanager.ErrSessionNotFound, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package sessionmanager
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CacheStoreOptions configures a CacheStore
+type CacheStoreOptions struct {
+	// Size is the maximum number of sessions kept in memory, by default 1000
+	Size int
+	// TTL is the time a session is served from memory before loading it again, by default 30 seconds
+	TTL time.Duration
+}
+
+// CacheStore is a store that keeps the recently used sessions in a bounded
+// in-memory LRU in front of another store
+//   - Saves and deletes are written through to the backing store
+//   - A cached session is served until its TTL or its expiration time is reached,
+//     call Invalidate when another process destroys or changes a session
+type CacheStore struct {
+	backend Store
+	opts    CacheStoreOptions
+	m       sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// hashes indexes the cached session ids by HashSessionID, the invalidation events carry the hash
+	hashes map[string]string
+}
+
+// cacheEntry is a session held by a CacheStore
+type cacheEntry struct {
+	record    SessionRecord
+	expiresAt time.Time
+	hash      string
+}
+
+// Verify that CacheStore implements Store
+var _ Store = (*CacheStore)(nil)
+
+// NewCacheStore is the constructor for cache store in front of backend
+func NewCacheStore(backend Store, opts CacheStoreOptions) *CacheStore {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 30 * time.Second
+	}
+	return &CacheStore{
+		backend: backend,
+		opts:    opts,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		hashes:  make(map[string]string),
+	}
+}
+
+// Load a session from memory or from the backing store if it is not cached
+func (cs *CacheStore) Load(ctx context.Context, sessionId string) (*Session, error) {
+	if record, ok := cs.get(sessionId); ok {
+		return record.Session(), nil
+	}
+
+	session, err := cs.backend.Load(ctx, sessionId)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			cs.Invalidate(sessionId)
+		}
+		return nil, err
+	}
+	cs.put(NewSessionRecord(session))
+	return session, nil
+}
+
+// Save a session in the backing store and in memory
+func (cs *CacheStore) Save(ctx context.Context, s *Session) error {
+	if err := cs.backend.Save(ctx, s); err != nil {
+		cs.Invalidate(s.SessionId())
+		return err
+	}
+	cs.put(NewSessionRecord(s))
+	return nil
+}
+
+// Delete a session from the backing store and from memory
+func (cs *CacheStore) Delete(ctx context.Context, sessionId string) error {
+	cs.Invalidate(sessionId)
+	return cs.backend.Delete(ctx, sessionId)
+}
+
+// Invalidate removes a session from memory, the next Load reads it from the backing store
+func (cs *CacheStore) Invalidate(sessionId string) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if element, ok := cs.entries[sessionId]; ok {
+		cs.remove(element)
+	}
+}
+
+// invalidateHash removes the session with a HashSessionID from memory
+func (cs *CacheStore) invalidateHash(hash string) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if sessionId, ok := cs.hashes[hash]; ok {
+		cs.remove(cs.entries[sessionId])
+	}
+}
+
+// remove drops a cached session, the lock must be held
+func (cs *CacheStore) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	cs.lru.Remove(element)
+	delete(cs.entries, entry.record.ID)
+	delete(cs.hashes, entry.hash)
+}
+
+// Len returns the number of sessions held in memory
+func (cs *CacheStore) Len() int {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	return cs.lru.Len()
+}
+
+// get returns a copy of a cached session if it is still fresh
+func (cs *CacheStore) get(sessionId string) (SessionRecord, bool) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	element, ok := cs.entries[sessionId]
+	if !ok {
+		return SessionRecord{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) || entry.record.IsExpired() {
+		cs.remove(element)
+		return SessionRecord{}, false
+	}
+	cs.lru.MoveToFront(element)
+	return entry.record.copy(), true
+}
+
+// put caches a session evicting the least recently used one if the cache is full
+func (cs *CacheStore) put(record SessionRecord) {
+	if record.IsExpired() {
+		cs.Invalidate(record.ID)
+		return
+	}
+	expiresAt := time.Now().Add(cs.opts.TTL)
+	if record.ExpirationTime.Before(expiresAt) {
+		expiresAt = record.ExpirationTime
+	}
+
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if element, ok := cs.entries[record.ID]; ok {
+		entry := element.Value.(*cacheEntry)
+		element.Value = &cacheEntry{record: record, expiresAt: expiresAt, hash: entry.hash}
+		cs.lru.MoveToFront(element)
+		return
+	}
+	hash := HashSessionID(record.ID)
+	cs.entries[record.ID] = cs.lru.PushFront(&cacheEntry{record: record, expiresAt: expiresAt, hash: hash})
+	cs.hashes[hash] = record.ID
+	if cs.lru.Len() > cs.opts.Size {
+		cs.remove(cs.lru.Back())
+	}
+}
@@ -0,0 +1,189 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore is an in-memory store that counts the loads
+type countingStore struct {
+	m        sync.Mutex
+	sessions map[string]*sessionmanager.Session
+	loads    int
+}
+
+func newCountingStore() *countingStore {
+	return &countingStore{sessions: map[string]*sessionmanager.Session{}}
+}
+
+func (cs *countingStore) Load(ctx context.Context, sessionId string) (*sessionmanager.Session, error) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	cs.loads++
+	stored, ok := cs.sessions[sessionId]
+	if !ok {
+		return nil, sessionmanager.ErrSessionNotFound
+	}
+	data := map[string]interface{}{}
+	for key, value := range stored.Data {
+		data[key] = value
+	}
+	s := sessionmanager.NewSession(data)
+	s.ID = stored.ID
+	s.ExpirationTime = stored.ExpirationTime
+	return s, nil
+}
+
+func (cs *countingStore) Save(ctx context.Context, s *sessionmanager.Session) error {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	data := map[string]interface{}{}
+	for key, value := range s.Data {
+		data[key] = value
+	}
+	stored := sessionmanager.NewSession(data)
+	stored.ID = s.ID
+	stored.ExpirationTime = s.ExpirationTime
+	cs.sessions[s.ID] = stored
+	return nil
+}
+
+func (cs *countingStore) Delete(ctx context.Context, sessionId string) error {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if _, ok := cs.sessions[sessionId]; !ok {
+		return sessionmanager.ErrSessionNotFound
+	}
+	delete(cs.sessions, sessionId)
+	return nil
+}
+
+func TestCacheStore_Load(t *testing.T) {
+	cases := map[string]struct {
+		opts   sessionmanager.CacheStoreOptions
+		action func(store *sessionmanager.CacheStore, backend *countingStore, ids []string)
+		loads  int
+		cached int
+	}{
+		"served from memory": {
+			opts:   sessionmanager.CacheStoreOptions{Size: 10, TTL: time.Minute},
+			action: func(store *sessionmanager.CacheStore, backend *countingStore, ids []string) {},
+			loads:  0,
+			cached: 3,
+		},
+
+		"least recently used evicted": {
+			opts: sessionmanager.CacheStoreOptions{Size: 2, TTL: time.Minute},
+			// ids[0] was evicted when saving ids[2], loading it evicts ids[1] and so on
+			action: func(store *sessionmanager.CacheStore, backend *countingStore, ids []string) {},
+			loads:  3,
+			cached: 2,
+		},
+
+		"local ttl expired": {
+			opts: sessionmanager.CacheStoreOptions{Size: 10, TTL: 20 * time.Millisecond},
+			action: func(store *sessionmanager.CacheStore, backend *countingStore, ids []string) {
+				time.Sleep(40 * time.Millisecond)
+			},
+			loads:  3,
+			cached: 3,
+		},
+
+		"invalidated": {
+			opts: sessionmanager.CacheStoreOptions{Size: 10, TTL: time.Minute},
+			action: func(store *sessionmanager.CacheStore, backend *countingStore, ids []string) {
+				store.Invalidate(ids[1])
+			},
+			loads:  1,
+			cached: 3,
+		},
+
+		"deleted by other node": {
+			opts: sessionmanager.CacheStoreOptions{Size: 10, TTL: time.Minute},
+			action: func(store *sessionmanager.CacheStore, backend *countingStore, ids []string) {
+				backend.Delete(context.Background(), ids[1])
+				store.Invalidate(ids[1])
+			},
+			loads:  1,
+			cached: 2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			backend := newCountingStore()
+			store := sessionmanager.NewCacheStore(backend, tc.opts)
+
+			var ids []string
+			for i := 0; i < 3; i++ {
+				s := sessionmanager.NewSession(map[string]interface{}{"index": i})
+				assert.NoError(t, store.Save(context.Background(), s))
+				ids = append(ids, s.ID)
+			}
+			tc.action(store, backend, ids)
+
+			for _, id := range ids {
+				store.Load(context.Background(), id)
+			}
+			assert.Equal(t, tc.loads, backend.loads)
+			assert.Equal(t, tc.cached, store.Len())
+		})
+	}
+}
+
+func TestCacheStore_WriteThrough(t *testing.T) {
+	cases := map[string]struct {
+		action   func(store *sessionmanager.CacheStore, s *sessionmanager.Session) error
+		expected map[string]interface{}
+		err      error
+	}{
+		"save": {
+			action: func(store *sessionmanager.CacheStore, s *sessionmanager.Session) error {
+				s.Set("user", "solrac")
+				return store.Save(context.Background(), s)
+			},
+			expected: map[string]interface{}{"user": "solrac"},
+		},
+
+		"delete": {
+			action: func(store *sessionmanager.CacheStore, s *sessionmanager.Session) error {
+				return store.Delete(context.Background(), s.ID)
+			},
+			err: sessionmanager.ErrSessionNotFound,
+		},
+
+		"changes to a loaded session are not cached until saved": {
+			action: func(store *sessionmanager.CacheStore, s *sessionmanager.Session) error {
+				loaded, err := store.Load(context.Background(), s.ID)
+				loaded.Set("unsaved", true)
+				return err
+			},
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			backend := newCountingStore()
+			store := sessionmanager.NewCacheStore(backend, sessionmanager.CacheStoreOptions{})
+			s := sessionmanager.NewSession(nil)
+			assert.NoError(t, store.Save(context.Background(), s))
+
+			assert.NoError(t, tc.action(store, s))
+
+			fromBackend, err := backend.Load(context.Background(), s.ID)
+			assert.Equal(t, tc.err, err)
+			fromCache, err := store.Load(context.Background(), s.ID)
+			assert.Equal(t, tc.err, err)
+			if tc.err == nil {
+				assert.Equal(t, tc.expected, fromBackend.Data)
+				assert.Equal(t, tc.expected, fromCache.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package sessionmanager
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// EvictionPolicy defines what CreateSession does when the session manager is full
+type EvictionPolicy int
+
+const (
+	// RejectWhenFull returns a *MaxSessionsError without creating the session
+	RejectWhenFull EvictionPolicy = iota
+	// EvictLeastRecentlyUsed destroys the session that was not requested for the longest time,
+	// its bookkeeping is O(1) per operation
+	EvictLeastRecentlyUsed
+	// EvictSoonestToExpire destroys the session with the closest expiration time, its
+	// bookkeeping is a heap, O(log n) when a session is added, removed or its expiration time changes
+	EvictSoonestToExpire
+)
+
+// MaxSessionsError is returned by CreateSession when the session manager
+// is full and the eviction policy is RejectWhenFull, or when a tenant is full
+type MaxSessionsError struct {
+	MaxSessions int
+	// Tenant is the full tenant, empty when the session manager is full
+	Tenant string
+}
+
+func (e *MaxSessionsError) Error() string {
+	if e.Tenant != "" {
+		return fmt.Sprintf("maximum number of sessions %d reached for tenant %s", e.MaxSessions, e.Tenant)
+	}
+	return fmt.Sprintf("maximum number of sessions %d reached", e.MaxSessions)
+}
+
+// SetMaxSessions limits the number of sessions held in memory by the session manager
+//   - A max of 0 removes the limit, which is the default
+//   - When the limit is reached the policy rejects the new session or evicts an existing one
+//   - Sessions loaded from a store are never rejected, but they can be evicted, with a store
+//     the evicted sessions are only removed from memory
+//   - A new session of a tenant only evicts sessions of the same tenant, and a new session
+//     without tenant only sessions without tenant, it is rejected if there is none to evict
+//   - EvictLeastRecentlyUsed costs O(1) per operation, EvictSoonestToExpire O(log n) per
+//     added or removed session and per change of expiration time
+func (sm *SessionManager) SetMaxSessions(max int, policy EvictionPolicy) {
+	sm.capacity.m.Lock()
+	defer sm.capacity.m.Unlock()
+	sm.capacity.max = max
+	sm.capacity.policy = policy
+}
+
+// makeRoom evicts sessions until a new session fits in memory, reject tells if
+// the session must be rejected when the policy says so, the lock must be held
+//   - The evicted sessions belong to the tenant of the new session, or have no tenant
+//     if it has none, so a tenant never evicts the sessions of another one
+func (sm *SessionManager) makeRoom(sessionId string, reject bool) error {
+	sm.capacity.m.Lock()
+	max, policy := sm.capacity.max, sm.capacity.policy
+	sm.capacity.m.Unlock()
+
+	if _, ok := sm.Sessions[sessionId]; ok || max <= 0 {
+		return nil
+	}
+	for len(sm.Sessions) >= max {
+		evicted := false
+		if policy != RejectWhenFull {
+			var err error
+			if evicted, err = sm.evict(policy, capacityGroup(sessionId)); err != nil {
+				return err
+			}
+		}
+		if evicted {
+			continue
+		}
+		if !reject {
+			return nil
+		}
+		sm.metrics.rejected.Add(1)
+		sm.log(slog.LevelWarn, "session rejected, maximum number of sessions reached", "", slog.Int("max_sessions", max))
+		return &MaxSessionsError{MaxSessions: max}
+	}
+	return nil
+}
+
+// evict destroys the session of the group chosen by the policy, the lock must be held
+func (sm *SessionManager) evict(policy EvictionPolicy, group string) (bool, error) {
+	for {
+		sessionId, ok := sm.capacity.victim(policy, group)
+		if !ok {
+			return false, nil
+		}
+		// The sessions map is exported, so the index can have sessions not held anymore
+		if _, ok := sm.Sessions[sessionId]; !ok {
+			sm.capacity.remove(sessionId)
+			continue
+		}
+		if err := sm.appendWAL(walEntry{Op: walDestroy, SessionId: sessionId}); err != nil {
+			return false, err
+		}
+		sm.capacity.remove(sessionId)
+		delete(sm.Sessions, sessionId)
+		sm.metrics.evicted.Add(1)
+		sm.log(slog.LevelInfo, "session evicted", sessionId)
+		return true, nil
+	}
+}
+
+// capacityGroup returns the group of a session in the eviction order, its tenant
+// id or an empty string if it has no tenant
+func capacityGroup(sessionId string) string {
+	if tenant, ok := sessionTenant(sessionId); ok {
+		return tenant
+	}
+	return ""
+}
+
+// capacity keeps the order in which the sessions of each group are evicted, a list
+// for the least recently used in O(1) and a heap for the soonest to expire in O(log n)
+type capacity struct {
+	m      sync.Mutex
+	max    int
+	policy EvictionPolicy
+	groups map[string]*evictionOrder
+	// byHash indexes the tracked session ids by HashSessionID, the invalidation events carry the hash
+	byHash map[string]string
+}
+
+// evictionOrder is the eviction order of the sessions of a group
+type evictionOrder struct {
+	lru      *list.List
+	lruIndex map[string]*list.Element
+	expiry   expiryHeap
+	expIndex map[string]*expiryItem
+}
+
+// newCapacity is the constructor for capacity without limit
+func newCapacity() *capacity {
+	return &capacity{groups: make(map[string]*evictionOrder), byHash: make(map[string]string)}
+}
+
+// order returns the eviction order of the group of a session, the lock must be held
+func (c *capacity) order(sessionId string, create bool) *evictionOrder {
+	group := capacityGroup(sessionId)
+	order, ok := c.groups[group]
+	if !ok && create {
+		order = &evictionOrder{
+			lru:      list.New(),
+			lruIndex: make(map[string]*list.Element),
+			expIndex: make(map[string]*expiryItem),
+		}
+		c.groups[group] = order
+	}
+	return order
+}
+
+// add starts tracking a session or refreshes it if it is already tracked
+func (c *capacity) add(sessionId string, expirationTime time.Time) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	order := c.order(sessionId, true)
+	if element, ok := order.lruIndex[sessionId]; ok {
+		order.lru.MoveToFront(element)
+	} else {
+		order.lruIndex[sessionId] = order.lru.PushFront(sessionId)
+		c.byHash[HashSessionID(sessionId)] = sessionId
+	}
+	if item, ok := order.expIndex[sessionId]; ok {
+		item.expirationTime = expirationTime
+		heap.Fix(&order.expiry, item.index)
+		return
+	}
+	item := &expiryItem{sessionId: sessionId, expirationTime: expirationTime}
+	heap.Push(&order.expiry, item)
+	order.expIndex[sessionId] = item
+}
+
+// touch marks a session as recently used
+func (c *capacity) touch(sessionId string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if order := c.order(sessionId, false); order != nil {
+		if element, ok := order.lruIndex[sessionId]; ok {
+			order.lru.MoveToFront(element)
+		}
+	}
+}
+
+// expirationChanged moves a session in the expiration order
+func (c *capacity) expirationChanged(sessionId string, expirationTime time.Time) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if order := c.order(sessionId, false); order != nil {
+		if item, ok := order.expIndex[sessionId]; ok {
+			item.expirationTime = expirationTime
+			heap.Fix(&order.expiry, item.index)
+		}
+	}
+}
+
+// remove stops tracking a session
+func (c *capacity) remove(sessionId string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	order := c.order(sessionId, false)
+	if order == nil {
+		return
+	}
+	if element, ok := order.lruIndex[sessionId]; ok {
+		order.lru.Remove(element)
+		delete(order.lruIndex, sessionId)
+		delete(c.byHash, HashSessionID(sessionId))
+	}
+	if item, ok := order.expIndex[sessionId]; ok {
+		heap.Remove(&order.expiry, item.index)
+		delete(order.expIndex, sessionId)
+	}
+	if order.lru.Len() == 0 {
+		delete(c.groups, capacityGroup(sessionId))
+	}
+}
+
+// reset stops tracking every session
+func (c *capacity) reset() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.groups = make(map[string]*evictionOrder)
+	c.byHash = make(map[string]string)
+}
+
+// sessionId returns the id of the tracked session with a HashSessionID
+func (c *capacity) sessionId(hash string) (string, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	sessionId, ok := c.byHash[hash]
+	return sessionId, ok
+}
+
+// tenantSessions returns the number of sessions of a tenant
+func (c *capacity) tenantSessions(tenant string) int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if order, ok := c.groups[tenant]; ok {
+		return order.lru.Len()
+	}
+	return 0
+}
+
+// victim returns the session of the group to evict according to the policy
+func (c *capacity) victim(policy EvictionPolicy, group string) (string, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	order, ok := c.groups[group]
+	if !ok {
+		return "", false
+	}
+	switch policy {
+	case EvictLeastRecentlyUsed:
+		if back := order.lru.Back(); back != nil {
+			return back.Value.(string), true
+		}
+	case EvictSoonestToExpire:
+		if len(order.expiry) > 0 {
+			return order.expiry[0].sessionId, true
+		}
+	}
+	return "", false
+}
+
+// expiryItem is a session in the expiration order
+type expiryItem struct {
+	sessionId      string
+	expirationTime time.Time
+	index          int
+}
+
+// expiryHeap implements heap.Interface ordering the sessions by expiration time
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool {
+	return h[i].expirationTime.Before(h[j].expirationTime)
+}
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,135 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_SetMaxSessions(t *testing.T) {
+	cases := map[string]struct {
+		policy sessionmanager.EvictionPolicy
+		// prepare runs with the manager full of the first two sessions
+		prepare  func(sm *sessionmanager.SessionManager, first, second sessionmanager.ISession)
+		err      bool
+		evicted  int
+		expected uint64
+	}{
+		"reject when full": {
+			policy:  sessionmanager.RejectWhenFull,
+			prepare: func(sm *sessionmanager.SessionManager, first, second sessionmanager.ISession) {},
+			err:     true,
+			evicted: -1,
+		},
+
+		"least recently used evicted": {
+			policy: sessionmanager.EvictLeastRecentlyUsed,
+			prepare: func(sm *sessionmanager.SessionManager, first, second sessionmanager.ISession) {
+				sm.GetSession(first.SessionId())
+			},
+			evicted:  1,
+			expected: 1,
+		},
+
+		"soonest to expire evicted": {
+			policy: sessionmanager.EvictSoonestToExpire,
+			prepare: func(sm *sessionmanager.SessionManager, first, second sessionmanager.ISession) {
+				first.SetExpirationTime(time.Now().Add(time.Minute))
+				second.SetExpirationTime(time.Now().Add(time.Hour))
+			},
+			evicted:  0,
+			expected: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetMaxSessions(2, tc.policy)
+			first, err := sm.CreateSession()
+			assert.NoError(t, err)
+			second, err := sm.CreateSession()
+			assert.NoError(t, err)
+			tc.prepare(sm, first, second)
+
+			third, err := sm.CreateSession()
+			if tc.err {
+				var full *sessionmanager.MaxSessionsError
+				assert.True(t, errors.As(err, &full))
+				assert.Equal(t, 2, full.MaxSessions)
+				assert.Equal(t, uint64(1), sm.Stats().Rejected)
+			} else {
+				assert.NoError(t, err)
+				_, err = sm.GetSession(third.SessionId())
+				assert.NoError(t, err)
+			}
+			assert.Len(t, sm.GetAllSessions(), 2)
+
+			for i, s := range []sessionmanager.ISession{first, second} {
+				_, err := sm.GetSession(s.SessionId())
+				assert.Equal(t, i == tc.evicted, err != nil)
+			}
+			assert.Equal(t, tc.expected, sm.Stats().Evicted)
+		})
+	}
+}
+
+// savedIdsStore records the ids of the sessions saved in its store
+type savedIdsStore struct {
+	sessionmanager.Store
+	ids []string
+}
+
+func (s *savedIdsStore) Save(ctx context.Context, session *sessionmanager.Session) error {
+	s.ids = append(s.ids, session.ID)
+	return s.Store.Save(ctx, session)
+}
+
+func TestSessionManager_SetMaxSessions_Store(t *testing.T) {
+	cases := map[string]struct {
+		create func(sm *sessionmanager.SessionManager) (sessionmanager.ISession, error)
+	}{
+		"manager full": {
+			create: func(sm *sessionmanager.SessionManager) (sessionmanager.ISession, error) {
+				sm.SetMaxSessions(1, sessionmanager.RejectWhenFull)
+				return sm.CreateSession()
+			},
+		},
+
+		"tenant full": {
+			create: func(sm *sessionmanager.SessionManager) (sessionmanager.ISession, error) {
+				acme, _ := sm.ForTenant("acme")
+				acme.SetMaxSessions(1)
+				if _, err := acme.CreateSession(); err != nil {
+					return nil, err
+				}
+				return acme.CreateSession()
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			boltStore := newBoltStore(t)
+			store := &savedIdsStore{Store: boltStore}
+			sm := sessionmanager.NewSessionManager()
+			sm.SetStore(store)
+			_, err := sm.CreateSession()
+			assert.NoError(t, err)
+
+			_, err = tc.create(sm)
+			var full *sessionmanager.MaxSessionsError
+			assert.True(t, errors.As(err, &full))
+
+			// The rejected session is not left in the store
+			rejected := store.ids[len(store.ids)-1]
+			_, err = store.Load(ctx, rejected)
+			assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package sessionmanager
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRFSecretKey is the session data key holding the CSRF secret of the session,
+// deleting it rotates the secret and invalidates the tokens issued before
+const CSRFSecretKey = "csrf.secret"
+
+// csrfSecretSize is the size in bytes of the CSRF secrets
+const csrfSecretSize = 32
+
+// ErrCSRFToken is wrapped by the errors returned for missing or invalid CSRF tokens
+var ErrCSRFToken = errors.New("invalid csrf token")
+
+// ErrCSRFOrigin is wrapped by the errors returned for requests sent from an origin that is not trusted
+var ErrCSRFOrigin = errors.New("csrf origin not trusted")
+
+// CSRFOptions configures the CSRF middleware of a session manager
+type CSRFOptions struct {
+	// HeaderName is the header holding the token, by default "X-CSRF-Token"
+	HeaderName string
+	// FieldName is the form field holding the token when the header is not set, by default "csrf_token"
+	FieldName string
+	// TrustedOrigins are the origins besides the request host allowed to send unsafe requests, like "https://app.example.com"
+	TrustedOrigins []string
+	// OnError writes the response of the rejected requests, by default 403 Forbidden
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// CSRFToken returns a new token for the CSRF secret of the session, creating the secret if needed
+//   - Each token is masked with a random pad, so tokens differ on every response and
+//     can not be recovered from compressed responses, but all of them stay valid
+func CSRFToken(s ISession) (string, error) {
+	secret, err := csrfSecret(s)
+	if err != nil {
+		return "", err
+	}
+	token := make([]byte, 2*csrfSecretSize)
+	if _, err := rand.Read(token[:csrfSecretSize]); err != nil {
+		return "", err
+	}
+	for i := range secret {
+		token[csrfSecretSize+i] = secret[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// VerifyCSRFToken checks that the token was issued by CSRFToken for the session
+func VerifyCSRFToken(s ISession, token string) error {
+	if token == "" {
+		return fmt.Errorf("%w: missing token", ErrCSRFToken)
+	}
+	masked, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(masked) != 2*csrfSecretSize {
+		return fmt.Errorf("%w: malformed token", ErrCSRFToken)
+	}
+	value, err := s.Get(CSRFSecretKey)
+	if err != nil {
+		return fmt.Errorf("%w: session has no secret", ErrCSRFToken)
+	}
+	secret, err := decodeCSRFSecret(value)
+	if err != nil {
+		return err
+	}
+	unmasked := make([]byte, csrfSecretSize)
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[csrfSecretSize+i]
+	}
+	if subtle.ConstantTimeCompare(unmasked, secret) != 1 {
+		return fmt.Errorf("%w: token does not match", ErrCSRFToken)
+	}
+	return nil
+}
+
+// csrfSecret returns the CSRF secret of the session, creating it if needed
+func csrfSecret(s ISession) ([]byte, error) {
+	var secret []byte
+	err := s.Update(func(tx SessionTx) error {
+		if value, err := tx.Get(CSRFSecretKey); err == nil {
+			secret, err = decodeCSRFSecret(value)
+			return err
+		}
+		secret = make([]byte, csrfSecretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		return tx.Set(CSRFSecretKey, base64.RawURLEncoding.EncodeToString(secret))
+	})
+	return secret, err
+}
+
+// decodeCSRFSecret decodes the CSRF secret stored in the session data
+func decodeCSRFSecret(value interface{}) ([]byte, error) {
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: secret has type %T", ErrCSRFToken, value)
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != csrfSecretSize {
+		return nil, fmt.Errorf("%w: malformed secret", ErrCSRFToken)
+	}
+	return secret, nil
+}
+
+// CSRFMiddleware returns a net/http middleware that protects the unsafe requests
+// of the sessions loaded by Middleware, which must wrap it
+//   - Requests with methods other than GET, HEAD, OPTIONS and TRACE need a token from
+//     CSRFToken in the header or the form field
+//   - Their Origin header, or the Referer header when there is no Origin, must have the
+//     request host or be a trusted origin, https requests without both are rejected
+func (sm *SessionManager) CSRFMiddleware(opts CSRFOptions) func(http.Handler) http.Handler {
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FieldName == "" {
+		opts.FieldName = "csrf_token"
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+			session, ok := SessionFromContext(r.Context())
+			if !ok {
+				sm.log(slog.LevelError, "csrf middleware used without session middleware", "")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			err := opts.checkOrigin(r)
+			if err == nil {
+				token := r.Header.Get(opts.HeaderName)
+				if token == "" {
+					token = r.PostFormValue(opts.FieldName)
+				}
+				err = VerifyCSRFToken(session, token)
+			}
+			if err != nil {
+				sm.log(slog.LevelWarn, "csrf check failed", session.SessionId(), slog.String("error", err.Error()))
+				opts.OnError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkOrigin checks that the request was sent from the request host or a trusted origin
+//   - The scheme of the request host is not compared, behind a proxy terminating TLS
+//     the requests of an https page reach the application over http
+func (opts CSRFOptions) checkOrigin(r *http.Request) error {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+		if source == "" {
+			if r.TLS != nil {
+				return fmt.Errorf("%w: missing origin and referer", ErrCSRFOrigin)
+			}
+			return nil
+		}
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: malformed origin %q", ErrCSRFOrigin, source)
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	origin := u.Scheme + "://" + u.Host
+	for _, trusted := range opts.TrustedOrigins {
+		if strings.EqualFold(origin, strings.TrimSuffix(trusted, "/")) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrCSRFOrigin, origin)
+}
@@ -0,0 +1,153 @@
+package sessionmanager_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFToken(t *testing.T) {
+	s := sessionmanager.NewSession(nil)
+	first, err := sessionmanager.CSRFToken(s)
+	assert.NoError(t, err)
+	second, err := sessionmanager.CSRFToken(s)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, 1, s.Len())
+
+	assert.NoError(t, sessionmanager.VerifyCSRFToken(s, first))
+	assert.NoError(t, sessionmanager.VerifyCSRFToken(s, second))
+
+	other := sessionmanager.NewSession(nil)
+	sessionmanager.CSRFToken(other)
+	for _, token := range []string{"", "malformed", strings.Repeat("A", 86)} {
+		assert.True(t, errors.Is(sessionmanager.VerifyCSRFToken(s, token), sessionmanager.ErrCSRFToken))
+	}
+	assert.True(t, errors.Is(sessionmanager.VerifyCSRFToken(other, first), sessionmanager.ErrCSRFToken))
+
+	// Rotating the secret invalidates the previous tokens
+	assert.NoError(t, s.Delete(sessionmanager.CSRFSecretKey))
+	assert.True(t, errors.Is(sessionmanager.VerifyCSRFToken(s, first), sessionmanager.ErrCSRFToken))
+}
+
+func TestSessionManager_CSRFMiddleware(t *testing.T) {
+	cases := map[string]struct {
+		method  string
+		header  bool
+		form    bool
+		token   string
+		origin  string
+		referer string
+		status  int
+	}{
+		"safe method": {
+			method: http.MethodGet,
+			status: http.StatusOK,
+		},
+
+		"missing token": {
+			method: http.MethodPost,
+			status: http.StatusForbidden,
+		},
+
+		"header token": {
+			method: http.MethodPost,
+			header: true,
+			origin: "http://example.com",
+			status: http.StatusOK,
+		},
+
+		"form token": {
+			method:  http.MethodPost,
+			form:    true,
+			referer: "http://example.com/form",
+			status:  http.StatusOK,
+		},
+
+		"token of another session": {
+			method: http.MethodDelete,
+			token:  "other",
+			status: http.StatusForbidden,
+		},
+
+		"foreign origin": {
+			method: http.MethodPost,
+			header: true,
+			origin: "http://evil.example",
+			status: http.StatusForbidden,
+		},
+
+		"foreign referer": {
+			method:  http.MethodPut,
+			header:  true,
+			referer: "http://evil.example/attack",
+			status:  http.StatusForbidden,
+		},
+
+		"https origin behind a proxy": {
+			method: http.MethodPost,
+			header: true,
+			origin: "https://example.com",
+			status: http.StatusOK,
+		},
+
+		"null origin": {
+			method: http.MethodPost,
+			header: true,
+			origin: "null",
+			status: http.StatusForbidden,
+		},
+
+		"trusted origin": {
+			method: http.MethodPost,
+			header: true,
+			origin: "https://app.example.com",
+			status: http.StatusOK,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			token, err := sessionmanager.CSRFToken(s)
+			assert.NoError(t, err)
+			if tc.token == "other" {
+				other, _ := sm.CreateSession()
+				token, _ = sessionmanager.CSRFToken(other)
+				tc.header = true
+			}
+
+			handler := sm.Middleware(sessionmanager.MiddlewareOptions{})(sm.CSRFMiddleware(sessionmanager.CSRFOptions{
+				TrustedOrigins: []string{"https://app.example.com/"},
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+			var body url.Values
+			if tc.form {
+				body = url.Values{"csrf_token": {token}}
+			}
+			r := httptest.NewRequest(tc.method, "http://example.com/", strings.NewReader(body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: s.SessionId()})
+			if tc.header {
+				r.Header.Set("X-CSRF-Token", token)
+			}
+			if tc.origin != "" {
+				r.Header.Set("Origin", tc.origin)
+			}
+			if tc.referer != "" {
+				r.Header.Set("Referer", tc.referer)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.status, w.Code)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package sessionmanager
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoCurrentSession is returned by CurrentSession when the context has no session and there is no fallback
+var ErrNoCurrentSession = errors.New("no current session")
+
+// DefaultSessionMode defines where CurrentSession looks for the session when the context has none
+type DefaultSessionMode int32
+
+const (
+	// DefaultSessionGlobal falls back to the default session of the session manager,
+	// for CLI and desktop apps with a single user, every goroutine shares it safely
+	DefaultSessionGlobal DefaultSessionMode = iota
+	// DefaultSessionContext never falls back, for servers, so a goroutine can not
+	// get the session of another request
+	DefaultSessionContext
+)
+
+// WithSession returns a copy of ctx carrying s as the current session, it is the
+// same context value set by Middleware and read by SessionFromContext
+func WithSession(ctx context.Context, s ISession) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+// SetDefaultSessionMode sets where CurrentSession looks for the session when the context has none,
+// by default DefaultSessionGlobal
+func (sm *SessionManager) SetDefaultSessionMode(mode DefaultSessionMode) {
+	sm.defaultMode.Store(int32(mode))
+}
+
+// CurrentSession returns the session of the context set with WithSession or by
+// Middleware, or the default session depending on the DefaultSessionMode
+//   - Revoked sessions and, if the manager avoids them, expired sessions are not returned
+func (sm *SessionManager) CurrentSession(ctx context.Context) (ISession, error) {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		if DefaultSessionMode(sm.defaultMode.Load()) == DefaultSessionContext {
+			return nil, ErrNoCurrentSession
+		}
+		return sm.GetDefaultSession()
+	}
+	if s, ok := session.(*Session); ok {
+		if err := s.revokedError(); err != nil {
+			return nil, err
+		}
+	}
+	sm.m.RLock()
+	avoidExpired := sm.AvoidExpired
+	sm.m.RUnlock()
+	if avoidExpired && session.IsExpired() {
+		return nil, expiredError(session.SessionId())
+	}
+	return session, nil
+}
+
+// CurrentSession returns the session of the context if it belongs to the tenant,
+// or the default session of the tenant depending on the DefaultSessionMode of the session manager
+func (t *Tenant) CurrentSession(ctx context.Context) (ISession, error) {
+	if session, ok := SessionFromContext(ctx); ok {
+		if !t.owns(session.SessionId()) {
+			return nil, notFoundError(session.SessionId())
+		}
+		session, err := t.sm.CurrentSession(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.m.RLock()
+		avoidExpired := t.avoidExpired
+		t.m.RUnlock()
+		if avoidExpired && session.IsExpired() {
+			return nil, expiredError(session.SessionId())
+		}
+		return session, nil
+	}
+	if DefaultSessionMode(t.sm.defaultMode.Load()) == DefaultSessionContext {
+		return nil, ErrNoCurrentSession
+	}
+	return t.GetDefaultSession()
+}
@@ -0,0 +1,128 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_CurrentSession(t *testing.T) {
+	cases := map[string]struct {
+		mode      sessionmanager.DefaultSessionMode
+		inContext bool
+		expected  string
+		err       error
+	}{
+		"global mode with context session": {
+			mode:      sessionmanager.DefaultSessionGlobal,
+			inContext: true,
+			expected:  "context",
+		},
+
+		"global mode falls back to default": {
+			mode:     sessionmanager.DefaultSessionGlobal,
+			expected: "default",
+		},
+
+		"context mode with context session": {
+			mode:      sessionmanager.DefaultSessionContext,
+			inContext: true,
+			expected:  "context",
+		},
+
+		"context mode never falls back": {
+			mode: sessionmanager.DefaultSessionContext,
+			err:  sessionmanager.ErrNoCurrentSession,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetDefaultSessionMode(tc.mode)
+			sessions := make(map[string]sessionmanager.ISession)
+			for _, name := range []string{"context", "default"} {
+				s, err := sm.CreateSession()
+				assert.NoError(t, err)
+				sessions[name] = s
+			}
+			assert.NoError(t, sm.SetAsDefaultSession(sessions["default"].SessionId()))
+
+			ctx := context.Background()
+			if tc.inContext {
+				ctx = sessionmanager.WithSession(ctx, sessions["context"])
+			}
+			s, err := sm.CurrentSession(ctx)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, sessions[tc.expected].SessionId(), s.SessionId())
+		})
+	}
+}
+
+func TestSessionManager_CurrentSession_Lifecycle(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	sm.SetAvoidExpired(true)
+	s, _ := sm.CreateSession()
+	ctx := sessionmanager.WithSession(context.Background(), s)
+
+	s.SetExpirationTime(time.Now().Add(-time.Minute))
+	_, err := sm.CurrentSession(ctx)
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionExpired))
+
+	s.SetExpirationTime(time.Now().Add(time.Minute))
+	assert.NoError(t, s.Invalidate(sessionmanager.RevokedLogout))
+	_, err = sm.CurrentSession(ctx)
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionRevoked))
+}
+
+func TestSessionManager_CurrentSession_Goroutines(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	sm.SetDefaultSessionMode(sessionmanager.DefaultSessionContext)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			ctx := sessionmanager.WithSession(context.Background(), s)
+			for j := 0; j < 50; j++ {
+				current, err := sm.CurrentSession(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, s.SessionId(), current.SessionId())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTenant_CurrentSession(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	acme, _ := sm.ForTenant("acme")
+	globex, _ := sm.ForTenant("globex")
+	s, _ := acme.CreateSession()
+	defaultSession, _ := globex.CreateSession()
+	assert.NoError(t, globex.SetAsDefaultSession(defaultSession.SessionId()))
+	ctx := sessionmanager.WithSession(context.Background(), s)
+
+	current, err := acme.CurrentSession(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, s.SessionId(), current.SessionId())
+
+	_, err = globex.CurrentSession(ctx)
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+
+	current, err = globex.CurrentSession(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, defaultSession.SessionId(), current.SessionId())
+}
@@ -0,0 +1,69 @@
+package sessionmanager
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Changes describes what changed in a session since it was loaded or saved
+type Changes struct {
+	// Set are the current values of the keys added or replaced
+	Set map[string]interface{}
+	// Deleted are the sorted keys removed
+	Deleted []string
+	// ExpirationTime is true if the expiration time was changed
+	ExpirationTime bool
+	// Active is true if the session was deactivated or reactivated
+	Active bool
+	// Client is true if the client metadata was changed
+	Client bool
+	// Fingerprint is true if the session was bound to a fingerprint
+	Fingerprint bool
+	// Access is true if the last access and the access count must be persisted,
+	// see SetAccessSaveInterval
+	Access bool
+}
+
+// Empty returns true if nothing changed
+func (c Changes) Empty() bool {
+	return len(c.Set) == 0 && len(c.Deleted) == 0 && !c.ExpirationTime && !c.Active && !c.Client && !c.Fingerprint && !c.Access
+}
+
+// Dirty returns true if the session changed since it was loaded or saved, so it must be saved again
+func (s *Session) Dirty() bool {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return !s.changes().Empty()
+}
+
+// Changes returns the changes made to the session since it was loaded or saved
+//   - A key changed back to its previous value is not reported
+//   - Values modified in place, without calling Set, are not tracked
+func (s *Session) Changes() Changes {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.changes()
+}
+
+// changes returns the changes made to the session, the lock must be held
+func (s *Session) changes() Changes {
+	changes := Changes{
+		Set:            make(map[string]interface{}),
+		ExpirationTime: s.expirationChanged,
+		Active:         s.activeChanged,
+		Client:         s.clientChanged,
+		Fingerprint:    s.boundChanged,
+		Access:         s.accessChanged,
+	}
+	for key, base := range s.base {
+		value, ok := s.Data[key]
+		switch {
+		case ok && (!base.existed || !reflect.DeepEqual(value, base.value)):
+			changes.Set[key] = value
+		case !ok && base.existed:
+			changes.Deleted = append(changes.Deleted, key)
+		}
+	}
+	sort.Strings(changes.Deleted)
+	return changes
+}
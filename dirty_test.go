@@ -0,0 +1,124 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_Changes(t *testing.T) {
+	cases := map[string]struct {
+		action   func(s *sessionmanager.Session)
+		expected sessionmanager.Changes
+	}{
+		"unchanged": {
+			action:   func(s *sessionmanager.Session) {},
+			expected: sessionmanager.Changes{Set: map[string]interface{}{}},
+		},
+
+		"set and deleted keys": {
+			action: func(s *sessionmanager.Session) {
+				s.Set("cart", 1)
+				s.Delete("user")
+			},
+			expected: sessionmanager.Changes{Set: map[string]interface{}{"cart": 1}, Deleted: []string{"user"}},
+		},
+
+		"replaced with the same value": {
+			action: func(s *sessionmanager.Session) {
+				s.Delete("user")
+				s.Set("user", "solrac")
+			},
+			expected: sessionmanager.Changes{Set: map[string]interface{}{}},
+		},
+
+		"cleared": {
+			action: func(s *sessionmanager.Session) {
+				s.Clear()
+			},
+			expected: sessionmanager.Changes{Set: map[string]interface{}{}, Deleted: []string{"theme", "user"}},
+		},
+
+		"expiration time changed": {
+			action: func(s *sessionmanager.Session) {
+				s.SetExpirationTime(time.Now().Add(time.Hour))
+			},
+			expected: sessionmanager.Changes{Set: map[string]interface{}{}, ExpirationTime: true},
+		},
+
+		"expired": {
+			action: func(s *sessionmanager.Session) {
+				s.SetExpirationTime(time.Now().Add(-time.Minute))
+				s.IsExpired()
+			},
+			expected: sessionmanager.Changes{Set: map[string]interface{}{}, ExpirationTime: true, Active: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := newBoltStore(t)
+			s := sessionmanager.NewSession(map[string]interface{}{"user": "solrac", "theme": "dark"})
+			assert.NoError(t, store.Save(context.Background(), s))
+			assert.False(t, s.Dirty())
+
+			tc.action(s)
+			assert.Equal(t, tc.expected, s.Changes())
+			assert.Equal(t, !tc.expected.Empty(), s.Dirty())
+		})
+	}
+}
+
+func TestSessionManager_SaveSession_Unchanged(t *testing.T) {
+	cases := map[string]struct {
+		action   func(s sessionmanager.ISession)
+		expected map[string]interface{}
+		writes   int
+	}{
+		"unchanged not written": {
+			action:   func(s sessionmanager.ISession) {},
+			expected: map[string]interface{}{"user": "solrac", "theme": "dark"},
+			writes:   0,
+		},
+
+		"changed fields written": {
+			action: func(s sessionmanager.ISession) {
+				s.Set("cart", 1)
+				s.Delete("theme")
+			},
+			expected: map[string]interface{}{"user": "solrac", "cart": 1},
+			writes:   2,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := newRESPServer(t, "")
+			store := sessionmanager.NewRedisStore(sessionmanager.RedisStoreOptions{Addr: server.addr()})
+			defer store.Close()
+			sm := sessionmanager.NewSessionManager()
+			// The access of GetSession is not persisted on its own with the default interval
+			sm.SetStore(store)
+
+			created, err := sm.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, created.SetMany(map[string]interface{}{"user": "solrac", "theme": "dark"}))
+			assert.NoError(t, sm.SaveSession(created))
+			s, err := sm.GetSession(created.SessionId())
+			assert.NoError(t, err)
+
+			before := server.changed("session:" + s.SessionId())
+			tc.action(s)
+			assert.NoError(t, sm.SaveSession(s))
+			// The HSET of the fields changed and the HDEL of the deleted ones
+			assert.Equal(t, tc.writes, server.changed("session:"+s.SessionId())-before)
+
+			loaded, err := store.Load(context.Background(), s.SessionId())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, loaded.All())
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package sessionmanager
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+)
+
+// SessionRecord is the serializable state of a session, used by the stores to persist it
+//   - Data values are encoded with encoding/gob, so custom types stored in sessions
+//     must be registered with gob.Register before saving or restoring them
+type SessionRecord struct {
+	ID             string
+	Data           map[string]interface{}
+	ExpirationTime time.Time
+	Expired        bool
+	Active         bool
+	Version        uint64
+	RevokedReason  RevocationReason
+	RevokedAt      time.Time
+	CreatedAt      time.Time
+	LastAccessedAt time.Time
+	AccessCount    uint64
+	Client         ClientInfo
+	Fingerprint    Fingerprint
+}
+
+// NewSessionRecord copies the state of the session into a record
+func NewSessionRecord(s *Session) SessionRecord {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.record()
+}
+
+// record copies the state of the session into a record, the lock must be held
+func (s *Session) record() SessionRecord {
+	return SessionRecord{
+		ID:             s.ID,
+		Data:           s.Data,
+		ExpirationTime: s.ExpirationTime,
+		Expired:        s.Expired,
+		Active:         s.Active,
+		Version:        s.Version,
+		RevokedReason:  s.RevokedReason,
+		RevokedAt:      s.RevokedAt,
+		CreatedAt:      s.CreatedAt,
+		LastAccessedAt: s.LastAccessedAt,
+		AccessCount:    s.AccessCount,
+		Client:         s.Client,
+		Fingerprint:    s.Fingerprint,
+	}.copy()
+}
+
+// copy returns a record with its own data map, so changes to it do not affect r
+func (r SessionRecord) copy() SessionRecord {
+	data := make(map[string]interface{}, len(r.Data))
+	for key, value := range r.Data {
+		data[key] = value
+	}
+	r.Data = data
+	return r
+}
+
+// Session creates a session from the record
+func (r SessionRecord) Session() *Session {
+	data := r.Data
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return &Session{
+		ID:             r.ID,
+		Data:           data,
+		m:              &sync.RWMutex{},
+		ExpirationTime: r.ExpirationTime,
+		Expired:        r.Expired,
+		Active:         r.Active,
+		Version:        r.Version,
+		RevokedReason:  r.RevokedReason,
+		RevokedAt:      r.RevokedAt,
+		CreatedAt:      r.CreatedAt,
+		LastAccessedAt: r.LastAccessedAt,
+		AccessCount:    r.AccessCount,
+		Client:         r.Client,
+		Fingerprint:    r.Fingerprint,
+		accessSavedAt:  r.LastAccessedAt,
+	}
+}
+
+// IsExpired returns true if the recorded session is expired or its expiration time passed
+func (r SessionRecord) IsExpired() bool {
+	return r.Expired || (r.Active && time.Now().After(r.ExpirationTime))
+}
+
+// EncodeSessionRecord encodes a record with encoding/gob
+func EncodeSessionRecord(r SessionRecord) ([]byte, error) {
+	return encodeGob(r)
+}
+
+// DecodeSessionRecord decodes a record encoded with EncodeSessionRecord
+func DecodeSessionRecord(data []byte) (SessionRecord, error) {
+	var r SessionRecord
+	err := decodeGob(data, &r)
+	return r, err
+}
+
+// encodeGob encodes a value with encoding/gob
+func encodeGob(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeGob decodes a value encoded with encodeGob
+func decodeGob(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// gobValue wraps a data value so values of any registered type can be encoded alone
+type gobValue struct {
+	V interface{}
+}
+
+// encodeValue encodes a single data value
+func encodeValue(v interface{}) ([]byte, error) {
+	return encodeGob(gobValue{V: v})
+}
+
+// decodeValue decodes a value encoded with encodeValue
+func decodeValue(data []byte) (interface{}, error) {
+	var v gobValue
+	if err := decodeGob(data, &v); err != nil {
+		return nil, err
+	}
+	return v.V, nil
+}
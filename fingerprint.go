@@ -0,0 +1,230 @@
+package sessionmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+)
+
+// ErrFingerprintMismatch is wrapped by the errors returned when a session is
+// used by a client that does not match the fingerprint it is bound to
+var ErrFingerprintMismatch = errors.New("session fingerprint mismatch")
+
+// Fingerprint identifies the client a session was created for
+//   - UserAgent and Certificate are SHA-256 hashes, so the fingerprint does not keep them
+//   - Empty fields were not known when the session was bound and are not checked
+type Fingerprint struct {
+	IP          string
+	UserAgent   string
+	Certificate string
+}
+
+// NewFingerprint is the constructor for the fingerprint of a client, certificate can be nil
+func NewFingerprint(ip string, userAgent string, certificate *x509.Certificate) Fingerprint {
+	fingerprint := Fingerprint{IP: ip}
+	if userAgent != "" {
+		fingerprint.UserAgent = fingerprintHash([]byte(userAgent))
+	}
+	if certificate != nil {
+		fingerprint.Certificate = fingerprintHash(certificate.Raw)
+	}
+	return fingerprint
+}
+
+// IsZero returns true if the fingerprint has no fields
+func (f Fingerprint) IsZero() bool {
+	return f == Fingerprint{}
+}
+
+// fingerprintHash returns the hex encoded SHA-256 hash of data
+func fingerprintHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FingerprintField is a field of a fingerprint checked by a BindingPolicy
+type FingerprintField string
+
+const (
+	FingerprintIP          FingerprintField = "ip"
+	FingerprintUserAgent   FingerprintField = "user_agent"
+	FingerprintCertificate FingerprintField = "certificate"
+)
+
+// BindingAction defines what happens when a session is used by a client that does not match its fingerprint
+type BindingAction int
+
+const (
+	// BindReject refuses the request, the session stays valid for the bound client
+	BindReject BindingAction = iota
+	// BindFlag allows the request and reports the mismatch, so the application can decide
+	BindFlag
+	// BindReauthenticate invalidates the session with RevokedFingerprintMismatch, so the
+	// client has to authenticate again in a new session
+	BindReauthenticate
+)
+
+func (a BindingAction) String() string {
+	switch a {
+	case BindReject:
+		return "reject"
+	case BindFlag:
+		return "flag"
+	case BindReauthenticate:
+		return "reauthenticate"
+	}
+	return fmt.Sprintf("BindingAction(%d)", int(a))
+}
+
+// BindingPolicy defines which fields of the fingerprint of a session are checked and what happens on a mismatch
+type BindingPolicy struct {
+	IP          bool
+	UserAgent   bool
+	Certificate bool
+	// IPv4PrefixBits is the prefix of an IPv4 address that must match, by default 24,
+	// so clients moving inside the same network, like mobile clients, keep their session
+	IPv4PrefixBits int
+	// IPv6PrefixBits is the prefix of an IPv6 address that must match, by default 64
+	IPv6PrefixBits int
+	Action         BindingAction
+}
+
+// FingerprintError is returned when a session is used by a client that does not match its fingerprint
+type FingerprintError struct {
+	SessionId string
+	Fields    []FingerprintField
+	Action    BindingAction
+}
+
+func (e *FingerprintError) Error() string {
+	fields := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = string(field)
+	}
+	return fmt.Sprintf("Session ID %s fingerprint mismatch: %s", e.SessionId, strings.Join(fields, ", "))
+}
+
+// Is makes errors.Is(err, ErrFingerprintMismatch) true for a *FingerprintError
+func (e *FingerprintError) Is(target error) bool {
+	return target == ErrFingerprintMismatch
+}
+
+// mismatch returns the fields of the bound fingerprint checked by the policy that do not match the client fingerprint
+func (p BindingPolicy) mismatch(bound Fingerprint, client Fingerprint) []FingerprintField {
+	var fields []FingerprintField
+	if p.IP && bound.IP != "" && !p.ipMatches(bound.IP, client.IP) {
+		fields = append(fields, FingerprintIP)
+	}
+	if p.UserAgent && bound.UserAgent != "" && bound.UserAgent != client.UserAgent {
+		fields = append(fields, FingerprintUserAgent)
+	}
+	if p.Certificate && bound.Certificate != "" && bound.Certificate != client.Certificate {
+		fields = append(fields, FingerprintCertificate)
+	}
+	return fields
+}
+
+// ipMatches returns true if both addresses are in the same network prefix,
+// addresses that can not be parsed must be equal
+func (p BindingPolicy) ipMatches(bound string, client string) bool {
+	boundAddr, err := netip.ParseAddr(bound)
+	if err != nil {
+		return bound == client
+	}
+	clientAddr, err := netip.ParseAddr(client)
+	if err != nil {
+		return false
+	}
+	boundAddr, clientAddr = boundAddr.Unmap(), clientAddr.Unmap()
+	if boundAddr.Is4() != clientAddr.Is4() {
+		return false
+	}
+	bits := p.IPv6PrefixBits
+	if boundAddr.Is4() {
+		bits = p.IPv4PrefixBits
+	}
+	boundPrefix, err := boundAddr.Prefix(bits)
+	if err != nil {
+		return boundAddr == clientAddr
+	}
+	return boundPrefix.Contains(clientAddr)
+}
+
+// Bind records the fingerprint of the client the session belongs to, the
+// session manager checks it with CheckFingerprint when a binding policy is set
+func (s *Session) Bind(fingerprint Fingerprint) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if fingerprint == s.Fingerprint {
+		return nil
+	}
+	if err := s.notify(sessionChange{Kind: changeBind, Fingerprint: fingerprint}); err != nil {
+		return err
+	}
+	s.Fingerprint = fingerprint
+	return nil
+}
+
+// boundFingerprint returns the fingerprint the session is bound to
+func (s *Session) boundFingerprint() Fingerprint {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.Fingerprint
+}
+
+// SetBindingPolicy makes the session manager check the fingerprint of the bound sessions
+//   - A nil policy disables the checks, which is the default
+//   - The middleware binds the new sessions and checks the existing ones on each request
+func (sm *SessionManager) SetBindingPolicy(policy *BindingPolicy) {
+	if policy == nil {
+		sm.binding.Store(nil)
+		return
+	}
+	p := *policy
+	if p.IPv4PrefixBits == 0 {
+		p.IPv4PrefixBits = 24
+	}
+	if p.IPv6PrefixBits == 0 {
+		p.IPv6PrefixBits = 64
+	}
+	sm.binding.Store(&p)
+}
+
+// CheckFingerprint compares the fingerprint of the client using a session with
+// the fingerprint the session is bound to and applies the action of the binding policy
+//   - It returns a *FingerprintError on a mismatch whatever the action, with BindFlag
+//     the session can still be used
+//   - Sessions that are not bound and managers without a binding policy are not checked
+func (sm *SessionManager) CheckFingerprint(ctx context.Context, s ISession, fingerprint Fingerprint) error {
+	policy := sm.binding.Load()
+	if policy == nil {
+		return nil
+	}
+	session, ok := s.(*Session)
+	if !ok {
+		return fmt.Errorf("Session ID %s can not be checked, type %T is not supported", s.SessionId(), s)
+	}
+	fields := policy.mismatch(session.boundFingerprint(), fingerprint)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	mismatch := &FingerprintError{SessionId: session.ID, Fields: fields, Action: policy.Action}
+	sm.metrics.fingerprintMismatches.Add(1)
+	sm.log(slog.LevelWarn, "session fingerprint mismatch", session.ID, slog.String("fields", fmt.Sprint(fields)), slog.String("action", policy.Action.String()))
+	if policy.Action == BindReauthenticate {
+		if err := session.Invalidate(RevokedFingerprintMismatch); err != nil {
+			return err
+		}
+		if err := sm.SaveSessionContext(ctx, session); err != nil {
+			return err
+		}
+	}
+	return mismatch
+}
@@ -0,0 +1,201 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_CheckFingerprint(t *testing.T) {
+	bound := sessionmanager.NewFingerprint("198.51.100.10", "Mozilla/5.0", &x509.Certificate{Raw: []byte("client certificate")})
+	policy := sessionmanager.BindingPolicy{IP: true, UserAgent: true, Certificate: true}
+
+	cases := map[string]struct {
+		policy *sessionmanager.BindingPolicy
+		bound  sessionmanager.Fingerprint
+		client sessionmanager.Fingerprint
+		fields []sessionmanager.FingerprintField
+	}{
+		"same client": {
+			policy: &policy,
+			bound:  bound,
+			client: bound,
+		},
+
+		"ip in the same network": {
+			policy: &policy,
+			bound:  bound,
+			client: sessionmanager.NewFingerprint("198.51.100.200", "Mozilla/5.0", &x509.Certificate{Raw: []byte("client certificate")}),
+		},
+
+		"ip in another network": {
+			policy: &policy,
+			bound:  bound,
+			client: sessionmanager.NewFingerprint("203.0.113.10", "Mozilla/5.0", &x509.Certificate{Raw: []byte("client certificate")}),
+			fields: []sessionmanager.FingerprintField{sessionmanager.FingerprintIP},
+		},
+
+		"exact ip required": {
+			policy: &sessionmanager.BindingPolicy{IP: true, IPv4PrefixBits: 32},
+			bound:  bound,
+			client: sessionmanager.NewFingerprint("198.51.100.11", "Mozilla/5.0", nil),
+			fields: []sessionmanager.FingerprintField{sessionmanager.FingerprintIP},
+		},
+
+		"ipv6 in the same network": {
+			policy: &policy,
+			bound:  sessionmanager.NewFingerprint("2001:db8:1:2::1", "", nil),
+			client: sessionmanager.NewFingerprint("2001:db8:1:2:ffff::9", "", nil),
+		},
+
+		"ip family changed": {
+			policy: &policy,
+			bound:  sessionmanager.NewFingerprint("2001:db8::1", "", nil),
+			client: sessionmanager.NewFingerprint("198.51.100.10", "", nil),
+			fields: []sessionmanager.FingerprintField{sessionmanager.FingerprintIP},
+		},
+
+		"user agent and certificate changed": {
+			policy: &policy,
+			bound:  bound,
+			client: sessionmanager.NewFingerprint("198.51.100.10", "curl/8.0", nil),
+			fields: []sessionmanager.FingerprintField{sessionmanager.FingerprintUserAgent, sessionmanager.FingerprintCertificate},
+		},
+
+		"field not checked": {
+			policy: &sessionmanager.BindingPolicy{IP: true},
+			bound:  bound,
+			client: sessionmanager.NewFingerprint("198.51.100.10", "curl/8.0", nil),
+		},
+
+		"session not bound": {
+			policy: &policy,
+			client: bound,
+		},
+
+		"no policy": {
+			bound:  bound,
+			client: sessionmanager.NewFingerprint("203.0.113.10", "curl/8.0", nil),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetBindingPolicy(tc.policy)
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, s.(*sessionmanager.Session).Bind(tc.bound))
+
+			err = sm.CheckFingerprint(context.Background(), s, tc.client)
+			if tc.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, sessionmanager.ErrFingerprintMismatch))
+			var mismatch *sessionmanager.FingerprintError
+			assert.True(t, errors.As(err, &mismatch))
+			assert.Equal(t, tc.fields, mismatch.Fields)
+			assert.Equal(t, uint64(1), sm.Stats().FingerprintMismatches)
+			assert.True(t, s.IsActive())
+		})
+	}
+}
+
+func TestSessionManager_Middleware_Binding(t *testing.T) {
+	cases := map[string]struct {
+		action   sessionmanager.BindingAction
+		status   int
+		replaced bool
+		flagged  bool
+		revoked  bool
+	}{
+		"reject": {
+			action: sessionmanager.BindReject,
+			status: http.StatusForbidden,
+		},
+
+		"flag": {
+			action:  sessionmanager.BindFlag,
+			status:  http.StatusOK,
+			flagged: true,
+		},
+
+		"reauthenticate": {
+			action:   sessionmanager.BindReauthenticate,
+			status:   http.StatusOK,
+			replaced: true,
+			flagged:  true,
+			revoked:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetBindingPolicy(&sessionmanager.BindingPolicy{IP: true, UserAgent: true, Action: tc.action})
+
+			var handled sessionmanager.ISession
+			var flagged bool
+			handler := sm.Middleware(sessionmanager.MiddlewareOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled, _ = sessionmanager.SessionFromContext(r.Context())
+				_, flagged = sessionmanager.FingerprintMismatch(r.Context())
+			}))
+			request := func(ip string, sessionId string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = ip + ":40000"
+				r.Header.Set("User-Agent", "Mozilla/5.0")
+				if sessionId != "" {
+					r.AddCookie(&http.Cookie{Name: "session_id", Value: sessionId})
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				return w
+			}
+
+			request("198.51.100.10", "")
+			created := handled
+			assert.Equal(t, sessionmanager.NewFingerprint("198.51.100.10", "Mozilla/5.0", nil), created.(*sessionmanager.Session).Fingerprint)
+
+			handled = nil
+			request("198.51.100.99", created.SessionId())
+			assert.Equal(t, created, handled)
+			assert.False(t, flagged)
+
+			handled = nil
+			w := request("203.0.113.10", created.SessionId())
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, tc.flagged, flagged)
+			assert.Equal(t, tc.revoked, created.(*sessionmanager.Session).IsRevoked())
+			if tc.status != http.StatusOK {
+				assert.Nil(t, handled)
+				return
+			}
+			assert.Equal(t, tc.replaced, handled.SessionId() != created.SessionId())
+		})
+	}
+}
+
+func TestStore_Fingerprint(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			s := sessionmanager.NewSession(nil)
+			fingerprint := sessionmanager.NewFingerprint("198.51.100.10", "Mozilla/5.0", &x509.Certificate{Raw: []byte("client certificate")})
+			assert.NoError(t, s.Bind(fingerprint))
+			assert.True(t, s.Changes().Fingerprint)
+			assert.NoError(t, store.Save(ctx, s))
+
+			loaded, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, fingerprint, loaded.Fingerprint)
+		})
+	}
+}
@@ -38,4 +38,26 @@ type ISession interface {
 	IsExpired() bool
 	// IsActive checks if session is active
 	IsActive() bool
+	// Keys returns the sorted keys of the session
+	Keys() []string
+	// Len returns the number of keys of the session
+	Len() int
+	// All returns a copy of the session data
+	All() map[string]interface{}
+	// Clear deletes every value of the session
+	Clear() error
+	// SetMany sets several values to session
+	SetMany(values map[string]interface{}) error
+	// DeleteMany deletes several values from session
+	DeleteMany(keys ...string) error
+	// Update runs fn with the session locked, applying its changes only if it succeeds
+	Update(fn func(tx SessionTx) error) error
+	// Invalidate deactivates the session recording why
+	Invalidate(reason RevocationReason) error
+	// Reactivate makes an invalidated or expired session active again
+	Reactivate() error
+	// Metadata returns when the session was created and used and by which client
+	Metadata() Metadata
+	// SetClient records the client using the session
+	SetClient(client ClientInfo) error
 }
@@ -0,0 +1,455 @@
+package sessionmanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InvalidationEventType identifies why a session must be evicted from the other processes
+type InvalidationEventType uint8
+
+const (
+	// EventDestroyed is published when a session is destroyed
+	EventDestroyed InvalidationEventType = iota + 1
+	// EventUpdated is published when a session is saved with changes
+	EventUpdated
+	// EventRegenerated is published when a session is replaced by RegenerateSession,
+	// the session id of the event is the old one
+	EventRegenerated
+)
+
+// InvalidationEvent is a message broadcasted between session managers
+type InvalidationEvent struct {
+	Type InvalidationEventType `json:"type"`
+	// SessionHash is the HashSessionID of the session, the id itself is a credential
+	// and never leaves the process
+	SessionHash string `json:"session_hash"`
+	// Origin identifies the session manager that published the event
+	Origin string `json:"origin"`
+}
+
+// InvalidationBus broadcasts invalidation events between session managers
+type InvalidationBus interface {
+	// Publish an event to every subscriber, including the ones of other processes
+	Publish(event InvalidationEvent) error
+	// Subscribe a handler to the events, it returns a function to unsubscribe it
+	Subscribe(handler func(InvalidationEvent)) (unsubscribe func())
+}
+
+// invalidator is implemented by the stores that keep local copies of the sessions
+type invalidator interface {
+	invalidateHash(hash string)
+}
+
+// SetInvalidationBus sets the bus used to keep the sessions held in memory consistent between processes
+//   - Destroying, saving and regenerating a session publishes an event to the other session managers
+//   - When an event is received the session is evicted from memory and from a cache store,
+//     so the next GetSession loads it from the store
+//   - Events carry the HashSessionID of the session, not its id
+//   - Events are not authenticated, a forged event evicts a session, which is lost
+//     without a store, so the buses must only be reachable by the session managers
+func (sm *SessionManager) SetInvalidationBus(bus InvalidationBus) {
+	sm.busM.Lock()
+	defer sm.busM.Unlock()
+	if sm.unsubscribe != nil {
+		sm.unsubscribe()
+		sm.unsubscribe = nil
+	}
+	sm.bus = bus
+	if bus != nil {
+		sm.unsubscribe = bus.Subscribe(sm.invalidate)
+	}
+}
+
+// publish broadcasts an event about a session if a bus was set
+func (sm *SessionManager) publish(eventType InvalidationEventType, sessionId string) {
+	sm.busM.Lock()
+	bus := sm.bus
+	sm.busM.Unlock()
+	if bus == nil {
+		return
+	}
+	event := InvalidationEvent{Type: eventType, SessionHash: HashSessionID(sessionId), Origin: sm.nodeId}
+	if err := bus.Publish(event); err != nil {
+		sm.log(slog.LevelError, "invalidation event publish failed", sessionId, slog.String("error", err.Error()))
+	}
+}
+
+// invalidate evicts the session of an event published by other session manager
+func (sm *SessionManager) invalidate(event InvalidationEvent) {
+	if event.Origin == sm.nodeId {
+		return
+	}
+	if cache, ok := sm.getStore().(invalidator); ok {
+		cache.invalidateHash(event.SessionHash)
+	}
+
+	// Only the sessions held by this manager can be invalidated, the others are not known by hash
+	sessionId, ok := sm.capacity.sessionId(event.SessionHash)
+	if !ok {
+		return
+	}
+	sm.m.Lock()
+	delete(sm.Sessions, sessionId)
+	sm.capacity.remove(sessionId)
+	sm.m.Unlock()
+	sm.log(slog.LevelDebug, "session invalidated", sessionId, slog.Int("event", int(event.Type)))
+}
+
+// subscribers is a set of event handlers shared by the bus implementations
+type subscribers struct {
+	m        sync.RWMutex
+	next     int
+	handlers map[int]func(InvalidationEvent)
+}
+
+// subscribe adds a handler and returns the function that removes it
+func (s *subscribers) subscribe(handler func(InvalidationEvent)) func() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[int]func(InvalidationEvent))
+	}
+	id := s.next
+	s.next++
+	s.handlers[id] = handler
+	return func() {
+		s.m.Lock()
+		defer s.m.Unlock()
+		delete(s.handlers, id)
+	}
+}
+
+// deliver calls every handler with the event
+func (s *subscribers) deliver(event InvalidationEvent) {
+	s.m.RLock()
+	handlers := make([]func(InvalidationEvent), 0, len(s.handlers))
+	for _, handler := range s.handlers {
+		handlers = append(handlers, handler)
+	}
+	s.m.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// LocalBus is an in-process invalidation bus for session managers of the same process
+type LocalBus struct {
+	subscribers subscribers
+}
+
+// Verify that LocalBus implements InvalidationBus
+var _ InvalidationBus = (*LocalBus)(nil)
+
+// NewLocalBus is the constructor for local bus
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish delivers the event to every subscriber before returning
+func (b *LocalBus) Publish(event InvalidationEvent) error {
+	b.subscribers.deliver(event)
+	return nil
+}
+
+// Subscribe a handler to the events
+func (b *LocalBus) Subscribe(handler func(InvalidationEvent)) func() {
+	return b.subscribers.subscribe(handler)
+}
+
+// UDPBusOptions configures an UDPBus
+type UDPBusOptions struct {
+	// ListenAddr is the address receiving the events, if its IP is a multicast
+	// group the bus joins it and publishes the events to the group
+	ListenAddr string
+	// Peers are the addresses of the other processes when not using multicast
+	Peers []string
+	// Interface used to join the multicast group, by default the system one
+	Interface *net.Interface
+}
+
+// UDPBus is an invalidation bus sending the events as UDP datagrams to a
+// multicast group or to a list of peers, it is meant for private networks
+//   - Delivery is not guaranteed, combine it with the TTL of the cache store
+//   - Events are accepted from any sender, only the session managers must reach the port
+type UDPBus struct {
+	conn        *net.UDPConn
+	targets     []*net.UDPAddr
+	subscribers subscribers
+	done        chan struct{}
+}
+
+// Verify that UDPBus implements InvalidationBus
+var _ InvalidationBus = (*UDPBus)(nil)
+
+// NewUDPBus is the constructor for udp bus, it starts receiving events until Close is called
+func NewUDPBus(opts UDPBusOptions) (*UDPBus, error) {
+	listenAddr, err := net.ResolveUDPAddr("udp", opts.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*net.UDPAddr
+	for _, peer := range opts.Peers {
+		addr, err := net.ResolveUDPAddr("udp", peer)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, addr)
+	}
+
+	var conn *net.UDPConn
+	if listenAddr.IP != nil && listenAddr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", opts.Interface, listenAddr)
+		targets = append(targets, listenAddr)
+	} else {
+		conn, err = net.ListenUDP("udp", listenAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		conn.Close()
+		return nil, errors.New("udp bus without peers or multicast group")
+	}
+
+	b := &UDPBus{conn: conn, targets: targets, done: make(chan struct{})}
+	go b.receive()
+	return b, nil
+}
+
+// Addr returns the address where the bus receives the events
+func (b *UDPBus) Addr() net.Addr {
+	return b.conn.LocalAddr()
+}
+
+// Publish sends the event to the multicast group or to every peer
+func (b *UDPBus) Publish(event InvalidationEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, target := range b.targets {
+		if _, err := b.conn.WriteToUDP(data, target); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe a handler to the events received
+func (b *UDPBus) Subscribe(handler func(InvalidationEvent)) func() {
+	return b.subscribers.subscribe(handler)
+}
+
+// Close stops receiving events
+func (b *UDPBus) Close() error {
+	err := b.conn.Close()
+	<-b.done
+	return err
+}
+
+// receive delivers the datagrams received until the connection is closed
+func (b *UDPBus) receive() {
+	defer close(b.done)
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := b.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		var event InvalidationEvent
+		if err := json.Unmarshal(buf[:n], &event); err != nil {
+			continue
+		}
+		b.subscribers.deliver(event)
+	}
+}
+
+// TCPBusOptions configures a TCPBus
+type TCPBusOptions struct {
+	// ListenAddr is the address receiving the events
+	ListenAddr string
+	// Peers are the addresses of the other processes
+	Peers []string
+	// Timeout bounds the connection to a peer and the writes of an event, by default 5 seconds
+	Timeout time.Duration
+}
+
+// TCPBus is an invalidation bus sending the events as JSON lines over a TCP
+// connection to each peer, for networks without multicast or dropping datagrams
+//   - Events reach each peer in the order they were published
+//   - Connections are opened with the first event and again after an error, the
+//     events published while a peer is not reachable are lost for it
+//   - Connections are accepted from anyone, only the session managers must reach the port
+type TCPBus struct {
+	listener    net.Listener
+	opts        TCPBusOptions
+	subscribers subscribers
+	// m serializes the publications, so it also guards the peer connections
+	m      sync.Mutex
+	peers  map[string]net.Conn
+	connsM sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed atomic.Bool
+	done   sync.WaitGroup
+}
+
+// Verify that TCPBus implements InvalidationBus
+var _ InvalidationBus = (*TCPBus)(nil)
+
+// NewTCPBus is the constructor for tcp bus, it starts receiving events until Close is called
+func NewTCPBus(opts TCPBusOptions) (*TCPBus, error) {
+	if len(opts.Peers) == 0 {
+		return nil, errors.New("tcp bus without peers")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	listener, err := net.Listen("tcp", opts.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &TCPBus{
+		listener: listener,
+		opts:     opts,
+		peers:    make(map[string]net.Conn),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	b.done.Add(1)
+	go b.accept()
+	return b, nil
+}
+
+// Addr returns the address where the bus receives the events
+func (b *TCPBus) Addr() net.Addr {
+	return b.listener.Addr()
+}
+
+// Publish sends the event to every peer
+func (b *TCPBus) Publish(event InvalidationEvent) error {
+	if b.closed.Load() {
+		return net.ErrClosed
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	b.m.Lock()
+	defer b.m.Unlock()
+	var errs []error
+	for _, peer := range b.opts.Peers {
+		if err := b.send(peer, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send writes an event to a peer, a broken connection is opened again once, the lock must be held
+func (b *TCPBus) send(peer string, data []byte) error {
+	for attempt := 1; ; attempt++ {
+		conn, ok := b.peers[peer]
+		if !ok {
+			var err error
+			if conn, err = net.DialTimeout("tcp", peer, b.opts.Timeout); err != nil {
+				return err
+			}
+			b.peers[peer] = conn
+		}
+		conn.SetWriteDeadline(time.Now().Add(b.opts.Timeout))
+		_, err := conn.Write(data)
+		if err == nil {
+			return nil
+		}
+		conn.Close()
+		delete(b.peers, peer)
+		if attempt >= 2 {
+			return err
+		}
+	}
+}
+
+// Subscribe a handler to the events received
+func (b *TCPBus) Subscribe(handler func(InvalidationEvent)) func() {
+	return b.subscribers.subscribe(handler)
+}
+
+// Close stops receiving events and closes the connections
+func (b *TCPBus) Close() error {
+	b.closed.Store(true)
+	err := b.listener.Close()
+
+	b.m.Lock()
+	for peer, conn := range b.peers {
+		conn.Close()
+		delete(b.peers, peer)
+	}
+	b.m.Unlock()
+	b.connsM.Lock()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.connsM.Unlock()
+
+	b.done.Wait()
+	return err
+}
+
+// accept receives the connections of the peers until the listener is closed
+func (b *TCPBus) accept() {
+	defer b.done.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		b.connsM.Lock()
+		if b.closed.Load() {
+			b.connsM.Unlock()
+			conn.Close()
+			return
+		}
+		b.conns[conn] = struct{}{}
+		b.done.Add(1)
+		b.connsM.Unlock()
+		go b.receive(conn)
+	}
+}
+
+// receive delivers the events read from a connection until it is closed
+func (b *TCPBus) receive(conn net.Conn) {
+	defer b.done.Done()
+	defer func() {
+		b.connsM.Lock()
+		delete(b.conns, conn)
+		b.connsM.Unlock()
+		conn.Close()
+	}()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024)
+	for scanner.Scan() {
+		var event InvalidationEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		b.subscribers.deliver(event)
+	}
+}
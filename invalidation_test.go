@@ -0,0 +1,220 @@
+package sessionmanager_test
+
+import (
+	"encoding/json"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// networkBus is an invalidation bus listening on an address
+type networkBus interface {
+	sessionmanager.InvalidationBus
+	Addr() net.Addr
+	Close() error
+}
+
+// meshBuses creates n buses publishing to each other
+func meshBuses(t *testing.T, n int, newBus func(listenAddr string, peers []string) (networkBus, error)) []sessionmanager.InvalidationBus {
+	addrs := make([]string, n)
+	// Every bus needs the address of the others, so the listening
+	// ports are taken first and the buses created over them
+	for i := range addrs {
+		probe, err := newBus("127.0.0.1:0", []string{"127.0.0.1:9"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[i] = probe.Addr().String()
+		probe.Close()
+	}
+
+	buses := make([]sessionmanager.InvalidationBus, n)
+	for i := range buses {
+		var peers []string
+		for j, addr := range addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		bus, err := newBus(addrs[i], peers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { bus.Close() })
+		buses[i] = bus
+	}
+	return buses
+}
+
+func TestSessionManager_SetInvalidationBus(t *testing.T) {
+	cases := map[string]struct {
+		buses func(t *testing.T, n int) []sessionmanager.InvalidationBus
+	}{
+		"local bus": {
+			buses: func(t *testing.T, n int) []sessionmanager.InvalidationBus {
+				bus := sessionmanager.NewLocalBus()
+				buses := make([]sessionmanager.InvalidationBus, n)
+				for i := range buses {
+					buses[i] = bus
+				}
+				return buses
+			},
+		},
+
+		"udp bus": {
+			buses: func(t *testing.T, n int) []sessionmanager.InvalidationBus {
+				return meshBuses(t, n, func(listenAddr string, peers []string) (networkBus, error) {
+					return sessionmanager.NewUDPBus(sessionmanager.UDPBusOptions{ListenAddr: listenAddr, Peers: peers})
+				})
+			},
+		},
+
+		"tcp bus": {
+			buses: func(t *testing.T, n int) []sessionmanager.InvalidationBus {
+				return meshBuses(t, n, func(listenAddr string, peers []string) (networkBus, error) {
+					return sessionmanager.NewTCPBus(sessionmanager.TCPBusOptions{ListenAddr: listenAddr, Peers: peers})
+				})
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			backend := newCountingStore()
+			buses := tc.buses(t, 3)
+			managers := make([]*sessionmanager.SessionManager, len(buses))
+			for i, bus := range buses {
+				managers[i] = sessionmanager.NewSessionManager()
+				managers[i].SetStore(sessionmanager.NewCacheStore(backend, sessionmanager.CacheStoreOptions{TTL: time.Hour}))
+				managers[i].SetInvalidationBus(bus)
+			}
+
+			s, err := managers[0].CreateSession()
+			assert.NoError(t, err)
+			for _, sm := range managers[1:] {
+				_, err := sm.GetSession(s.SessionId())
+				assert.NoError(t, err)
+			}
+
+			// An update is seen by the others instead of their cached copy
+			s.Set("user", "solrac")
+			assert.NoError(t, managers[0].SaveSession(s))
+			for _, sm := range managers[1:] {
+				assert.Eventually(t, func() bool {
+					loaded, err := sm.GetSession(s.SessionId())
+					if err != nil {
+						return false
+					}
+					value, _ := loaded.Get("user")
+					return value == "solrac"
+				}, time.Second, 10*time.Millisecond)
+			}
+
+			// A regenerated session id is evicted everywhere
+			regenerated, err := managers[0].RegenerateSession(s.SessionId())
+			assert.NoError(t, err)
+			for _, sm := range managers[1:] {
+				assert.Eventually(t, func() bool {
+					_, err := sm.GetSession(s.SessionId())
+					return err != nil
+				}, time.Second, 10*time.Millisecond)
+				_, err := sm.GetSession(regenerated.SessionId())
+				assert.NoError(t, err)
+			}
+			s = regenerated
+
+			// A destroyed session is evicted everywhere
+			assert.NoError(t, managers[1].DestroySession(s.SessionId()))
+			for _, sm := range []*sessionmanager.SessionManager{managers[0], managers[2]} {
+				assert.Eventually(t, func() bool {
+					_, err := sm.GetSession(s.SessionId())
+					return err != nil
+				}, time.Second, 10*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestLocalBus_Subscribe(t *testing.T) {
+	cases := map[string]struct {
+		unsubscribe bool
+		expected    int
+	}{
+		"subscribed": {
+			expected: 1,
+		},
+
+		"unsubscribed": {
+			unsubscribe: true,
+			expected:    0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			bus := sessionmanager.NewLocalBus()
+			received := 0
+			unsubscribe := bus.Subscribe(func(event sessionmanager.InvalidationEvent) { received++ })
+			if tc.unsubscribe {
+				unsubscribe()
+			}
+
+			assert.NoError(t, bus.Publish(sessionmanager.InvalidationEvent{Type: sessionmanager.EventDestroyed, SessionHash: "id"}))
+			assert.Equal(t, tc.expected, received)
+		})
+	}
+}
+
+func TestSessionManager_SetInvalidationBus_Hash(t *testing.T) {
+	bus := sessionmanager.NewLocalBus()
+	sm := sessionmanager.NewSessionManager()
+	sm.SetInvalidationBus(bus)
+	var events []sessionmanager.InvalidationEvent
+	bus.Subscribe(func(event sessionmanager.InvalidationEvent) { events = append(events, event) })
+
+	s, err := sm.CreateSession()
+	assert.NoError(t, err)
+	assert.NoError(t, sm.DestroySession(s.SessionId()))
+	assert.Len(t, events, 1)
+	data, err := json.Marshal(events[0])
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), s.SessionId())
+	assert.Equal(t, sessionmanager.HashSessionID(s.SessionId()), events[0].SessionHash)
+}
+
+func TestTCPBus_Reconnect(t *testing.T) {
+	listen := func(addr string, received *atomic.Int32) *sessionmanager.TCPBus {
+		bus, err := sessionmanager.NewTCPBus(sessionmanager.TCPBusOptions{ListenAddr: addr, Peers: []string{"127.0.0.1:9"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus.Subscribe(func(event sessionmanager.InvalidationEvent) { received.Add(1) })
+		return bus
+	}
+	var first, second atomic.Int32
+	peer := listen("127.0.0.1:0", &first)
+	addr := peer.Addr().String()
+	bus, err := sessionmanager.NewTCPBus(sessionmanager.TCPBusOptions{ListenAddr: "127.0.0.1:0", Peers: []string{addr}})
+	assert.NoError(t, err)
+	defer bus.Close()
+
+	event := sessionmanager.InvalidationEvent{Type: sessionmanager.EventDestroyed, SessionHash: "id"}
+	assert.NoError(t, bus.Publish(event))
+	assert.Eventually(t, func() bool { return first.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	// The peer restarts, the connection to it is opened again
+	assert.NoError(t, peer.Close())
+	peer = listen(addr, &second)
+	defer peer.Close()
+	assert.Eventually(t, func() bool {
+		bus.Publish(event)
+		return second.Load() > 0
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, bus.Close())
+	assert.Error(t, bus.Publish(event))
+}
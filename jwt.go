@@ -0,0 +1,491 @@
+package sessionmanager
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidToken is wrapped by the errors returned for session tokens that are
+// malformed, signed with an unknown key or whose signature does not match
+var ErrInvalidToken = errors.New("invalid session token")
+
+// JWTAlgorithm is the algorithm used to sign the session tokens
+type JWTAlgorithm string
+
+const (
+	JWTHS256 JWTAlgorithm = "HS256"
+	JWTEdDSA JWTAlgorithm = "EdDSA"
+)
+
+// JWTKey is a key used to sign or verify the session tokens
+type JWTKey struct {
+	// ID is sent in the kid header, so tokens signed with previous keys can still be verified
+	ID        string
+	Algorithm JWTAlgorithm
+	// Secret is the HS256 key, at least 32 bytes
+	Secret []byte
+	// PrivateKey is the EdDSA signing key, it can be nil for keys only used to verify
+	PrivateKey ed25519.PrivateKey
+	// PublicKey is the EdDSA verification key, by default the public key of PrivateKey
+	PublicKey ed25519.PublicKey
+}
+
+// DenyList keeps the ids of the JWT sessions revoked before they expire
+type DenyList interface {
+	// Deny revokes a session until its expiration time
+	Deny(ctx context.Context, sessionId string, reason RevocationReason, until time.Time) error
+	// Denied returns the reason a session was revoked and true if it is revoked
+	Denied(ctx context.Context, sessionId string) (RevocationReason, bool, error)
+	// DenyBefore revokes every session created until cutoff
+	DenyBefore(ctx context.Context, cutoff time.Time) error
+	// DeniedBefore returns the time until which the sessions are revoked, zero if it was never set
+	DeniedBefore(ctx context.Context) (time.Time, error)
+}
+
+// JWTOptions configures a JWTSessionManager
+type JWTOptions struct {
+	// Keys verify the tokens, the key with SigningKeyID signs them
+	Keys []JWTKey
+	// SigningKeyID is the id of the key signing the tokens, by default the first key
+	SigningKeyID string
+	// Issuer is set in the iss claim and required in the tokens if it is not empty
+	Issuer string
+	// TTL is the lifetime of the new sessions, by default 5 minutes like NewSession
+	TTL time.Duration
+	// DenyList revokes tokens before they expire, without it sessions can not be destroyed
+	DenyList DenyList
+}
+
+// JWTSessionManager is a stateless session manager, the data and the expiration
+// time of its sessions live in signed JSON Web Tokens held by the clients
+//   - The session id given to GetSession, DestroySession and SetAsDefaultSession is the token
+//   - Sessions must be signed again with Token after changing them
+//   - GetAllSessions is always empty, the manager does not hold the sessions
+//   - DestroyAllSessions rejects the tokens of the sessions created before it, the
+//     deny-list shares the cutoff with the other processes verifying the same tokens
+type JWTSessionManager struct {
+	m              sync.RWMutex
+	keys           atomic.Pointer[jwtKeys]
+	opts           JWTOptions
+	defaultSession *JWTSession
+	// destroyedAt is the DestroyAllSessions cutoff when there is no deny-list
+	destroyedAt time.Time
+}
+
+// jwtKeys are the keys of a JWTSessionManager by id
+type jwtKeys struct {
+	byId    map[string]JWTKey
+	signing JWTKey
+}
+
+// Verify that JWTSessionManager implements ISessionManager
+var _ ISessionManager = (*JWTSessionManager)(nil)
+
+// NewJWTSessionManager is the constructor for the stateless session manager
+func NewJWTSessionManager(opts JWTOptions) (*JWTSessionManager, error) {
+	if opts.TTL == 0 {
+		opts.TTL = 5 * time.Minute
+	}
+	sm := &JWTSessionManager{opts: opts}
+	if err := sm.SetKeys(opts.Keys, opts.SigningKeyID); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// SetKeys replaces the keys of the manager, to rotate the signing key add the new
+// key and keep the previous ones until the tokens they signed expire
+func (sm *JWTSessionManager) SetKeys(keys []JWTKey, signingKeyID string) error {
+	if len(keys) == 0 {
+		return errors.New("jwt: at least one key is required")
+	}
+	if signingKeyID == "" {
+		signingKeyID = keys[0].ID
+	}
+	set := &jwtKeys{byId: make(map[string]JWTKey, len(keys))}
+	for _, key := range keys {
+		switch key.Algorithm {
+		case JWTHS256:
+			if len(key.Secret) < sha256.Size {
+				return fmt.Errorf("jwt: key %s secret must have at least %d bytes", key.ID, sha256.Size)
+			}
+		case JWTEdDSA:
+			if key.PublicKey == nil && key.PrivateKey != nil {
+				key.PublicKey = key.PrivateKey.Public().(ed25519.PublicKey)
+			}
+			if len(key.PublicKey) != ed25519.PublicKeySize {
+				return fmt.Errorf("jwt: key %s has no valid public key", key.ID)
+			}
+		default:
+			return fmt.Errorf("jwt: key %s has unsupported algorithm %q", key.ID, key.Algorithm)
+		}
+		if _, ok := set.byId[key.ID]; ok {
+			return fmt.Errorf("jwt: duplicated key id %q", key.ID)
+		}
+		set.byId[key.ID] = key
+	}
+	signing, ok := set.byId[signingKeyID]
+	if !ok {
+		return fmt.Errorf("jwt: signing key %q not found", signingKeyID)
+	}
+	if signing.Algorithm == JWTEdDSA && len(signing.PrivateKey) != ed25519.PrivateKeySize {
+		return fmt.Errorf("jwt: signing key %s has no private key", signing.ID)
+	}
+	set.signing = signing
+	sm.keys.Store(set)
+	return nil
+}
+
+// GetSession verifies a token and returns its session
+func (sm *JWTSessionManager) GetSession(token string) (ISession, error) {
+	return sm.GetSessionContext(context.Background(), token)
+}
+
+// GetSessionContext verifies a token and returns its session, the context is passed to the deny-list
+func (sm *JWTSessionManager) GetSessionContext(ctx context.Context, token string) (ISession, error) {
+	session, err := sm.parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if sm.opts.DenyList != nil {
+		reason, denied, err := sm.opts.DenyList.Denied(ctx, session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("checking deny-list: %w", err)
+		}
+		if denied {
+			return nil, &RevokedError{SessionId: session.ID, Reason: reason}
+		}
+	}
+	destroyedAt, err := sm.cutoff(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !session.CreatedAt.After(destroyedAt) {
+		return nil, notFoundError(session.ID)
+	}
+	return session, nil
+}
+
+// cutoff returns the time until which DestroyAllSessions revoked the sessions
+func (sm *JWTSessionManager) cutoff(ctx context.Context) (time.Time, error) {
+	if sm.opts.DenyList != nil {
+		cutoff, err := sm.opts.DenyList.DeniedBefore(ctx)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("checking deny-list: %w", err)
+		}
+		return cutoff, nil
+	}
+	sm.m.RLock()
+	defer sm.m.RUnlock()
+	return sm.destroyedAt, nil
+}
+
+// CreateSession creates a new session, send the token returned by its Token method to the client
+func (sm *JWTSessionManager) CreateSession() (ISession, error) {
+	session := NewSession(nil)
+	// The exp claim is in seconds, the creation time keeps its nanoseconds in iat_ns
+	// and is compared with the cutoff of DestroyAllSessions by wall clock
+	session.CreatedAt = session.CreatedAt.Round(0)
+	session.LastAccessedAt = session.CreatedAt
+	session.ExpirationTime = session.CreatedAt.Add(sm.opts.TTL).Truncate(time.Second)
+	return &JWTSession{Session: session, manager: sm}, nil
+}
+
+// DestroySession revokes a token until it expires, it requires a deny-list
+func (sm *JWTSessionManager) DestroySession(token string) error {
+	session, err := sm.parse(token)
+	if err != nil {
+		return err
+	}
+	return session.Invalidate(RevokedLogout)
+}
+
+// SetAsDefaultSession verifies a token and sets its session as the default session
+func (sm *JWTSessionManager) SetAsDefaultSession(token string) error {
+	session, err := sm.GetSession(token)
+	if err != nil {
+		return err
+	}
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	sm.defaultSession = session.(*JWTSession)
+	return nil
+}
+
+// GetDefaultSession gets the default session
+func (sm *JWTSessionManager) GetDefaultSession() (ISession, error) {
+	sm.m.RLock()
+	defer sm.m.RUnlock()
+	if sm.defaultSession == nil {
+		return nil, fmt.Errorf("default session not set")
+	}
+	if err := sm.defaultSession.revokedError(); err != nil {
+		return nil, err
+	}
+	if sm.defaultSession.IsExpired() {
+		return nil, &sessionError{msg: "default session is expired", err: ErrSessionExpired}
+	}
+	return sm.defaultSession, nil
+}
+
+// GetAllSessions returns an empty map, the sessions are held by the clients
+func (sm *JWTSessionManager) GetAllSessions() map[string]ISession {
+	return make(map[string]ISession)
+}
+
+// DestroyAllSessions rejects the tokens of every session created until now
+//   - With a deny-list the cutoff is kept in it, so every process sharing it rejects them
+//   - Without a deny-list only this manager rejects them, until the process restarts
+//   - Tokens created by other processes are compared with the cutoff, so their clocks must agree
+func (sm *JWTSessionManager) DestroyAllSessions() error {
+	cutoff := time.Now().Round(0)
+	if sm.opts.DenyList != nil {
+		if err := sm.opts.DenyList.DenyBefore(context.Background(), cutoff); err != nil {
+			return err
+		}
+	}
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	if sm.opts.DenyList == nil {
+		sm.destroyedAt = cutoff
+	}
+	sm.defaultSession = nil
+	return nil
+}
+
+// SetAvoidExpired does nothing, expired tokens are always rejected since a stateless
+// token past its expiration time can not be told apart from a stolen one
+func (sm *JWTSessionManager) SetAvoidExpired(avoidExpired bool) {}
+
+// jwtHeader is the header of the session tokens
+type jwtHeader struct {
+	Algorithm JWTAlgorithm `json:"alg"`
+	Type      string       `json:"typ"`
+	KeyID     string       `json:"kid,omitempty"`
+}
+
+// jwtClaims are the claims of the session tokens
+type jwtClaims struct {
+	ID       string `json:"jti"`
+	Issuer   string `json:"iss,omitempty"`
+	IssuedAt int64  `json:"iat"`
+	// IssuedAtNanos are the nanoseconds of the creation time, so DestroyAllSessions
+	// tells apart the sessions created in the same second
+	IssuedAtNanos int64                  `json:"iat_ns,omitempty"`
+	ExpiresAt     int64                  `json:"exp"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+// sign returns the token of the claims signed with the signing key
+func (sm *JWTSessionManager) sign(claims jwtClaims) (string, error) {
+	key := sm.keys.Load().signing
+	header, err := json.Marshal(jwtHeader{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encoding claims: %w", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch key.Algorithm {
+	case JWTHS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case JWTEdDSA:
+		signature = ed25519.Sign(key.PrivateKey, []byte(signed))
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parse verifies the signature of a token and returns its session
+func (sm *JWTSessionManager) parse(token string) (*JWTSession, error) {
+	invalid := func(reason string) error {
+		return &sessionError{msg: "invalid session token: " + reason, err: ErrInvalidToken}
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, invalid("malformed header")
+	}
+	key, ok := sm.keys.Load().byId[header.KeyID]
+	if !ok {
+		return nil, invalid(fmt.Sprintf("unknown key %q", header.KeyID))
+	}
+	// The algorithm of the key is enforced, so a token can not choose how it is verified
+	if header.Algorithm != key.Algorithm {
+		return nil, invalid(fmt.Sprintf("algorithm %q does not match key %s", header.Algorithm, key.ID))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid("malformed signature")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch key.Algorithm {
+	case JWTHS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(signed)
+		ok = hmac.Equal(signature, mac.Sum(nil))
+	case JWTEdDSA:
+		ok = ed25519.Verify(key.PublicKey, signed, signature)
+	}
+	if !ok {
+		return nil, invalid("signature does not match")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, invalid("malformed claims")
+	}
+	if claims.ID == "" {
+		return nil, invalid("missing jti claim")
+	}
+	if sm.opts.Issuer != "" && claims.Issuer != sm.opts.Issuer {
+		return nil, invalid(fmt.Sprintf("issuer %q not accepted", claims.Issuer))
+	}
+	// Expired tokens are always rejected, the deny-list forgets them once they expire
+	if time.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, expiredError(claims.ID)
+	}
+
+	record := SessionRecord{
+		ID:             claims.ID,
+		Data:           claims.Data,
+		ExpirationTime: time.Unix(claims.ExpiresAt, 0),
+		Active:         true,
+		CreatedAt:      time.Unix(claims.IssuedAt, claims.IssuedAtNanos),
+		LastAccessedAt: time.Now(),
+	}
+	return &JWTSession{Session: record.Session(), manager: sm}, nil
+}
+
+// decodeJWTPart decodes a base64 encoded JSON part of a token
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// JWTSession is a session of a JWTSessionManager
+//   - Its data is encoded with encoding/json, so values read from a token have
+//     the JSON types, like float64 for numbers
+type JWTSession struct {
+	*Session
+	manager *JWTSessionManager
+}
+
+// Verify that JWTSession implements ISession
+var _ ISession = (*JWTSession)(nil)
+
+// Token signs the current state of the session, send it to the client after changing the session
+func (s *JWTSession) Token() (string, error) {
+	s.m.RLock()
+	claims := jwtClaims{
+		ID:            s.ID,
+		Issuer:        s.manager.opts.Issuer,
+		IssuedAt:      s.CreatedAt.Unix(),
+		IssuedAtNanos: int64(s.CreatedAt.Nanosecond()),
+		ExpiresAt:     s.ExpirationTime.Unix(),
+		Data:          s.record().Data,
+	}
+	s.m.RUnlock()
+	return s.manager.sign(claims)
+}
+
+// Invalidate revokes the tokens of the session until it expires, it requires a deny-list
+func (s *JWTSession) Invalidate(reason RevocationReason) error {
+	denyList := s.manager.opts.DenyList
+	if denyList == nil {
+		return fmt.Errorf("Session ID %s can not be revoked without a deny-list", s.ID)
+	}
+	s.m.RLock()
+	until := s.ExpirationTime
+	s.m.RUnlock()
+	if err := denyList.Deny(context.Background(), s.ID, reason, until); err != nil {
+		return err
+	}
+	return s.Session.Invalidate(reason)
+}
+
+// Reactivate makes an expired session active again, revoked tokens stay in the deny-list
+func (s *JWTSession) Reactivate() error {
+	if err := s.revokedError(); err != nil {
+		return err
+	}
+	return s.Session.Reactivate()
+}
+
+// MemoryDenyList keeps the revoked JWT sessions in memory until they expire
+type MemoryDenyList struct {
+	m       sync.Mutex
+	entries map[string]denyEntry
+	cutoff  time.Time
+}
+
+// denyEntry is a session revoked until a time
+type denyEntry struct {
+	reason RevocationReason
+	until  time.Time
+}
+
+// Verify that MemoryDenyList implements DenyList
+var _ DenyList = (*MemoryDenyList)(nil)
+
+// NewMemoryDenyList is the constructor for the in-memory deny-list
+func NewMemoryDenyList() *MemoryDenyList {
+	return &MemoryDenyList{entries: make(map[string]denyEntry)}
+}
+
+func (dl *MemoryDenyList) Deny(ctx context.Context, sessionId string, reason RevocationReason, until time.Time) error {
+	dl.m.Lock()
+	defer dl.m.Unlock()
+	now := time.Now()
+	// Entries of expired tokens are useless, remove them while adding new ones
+	for id, entry := range dl.entries {
+		if now.After(entry.until) {
+			delete(dl.entries, id)
+		}
+	}
+	dl.entries[sessionId] = denyEntry{reason: reason, until: until}
+	return nil
+}
+
+func (dl *MemoryDenyList) Denied(ctx context.Context, sessionId string) (RevocationReason, bool, error) {
+	dl.m.Lock()
+	defer dl.m.Unlock()
+	entry, ok := dl.entries[sessionId]
+	return entry.reason, ok, nil
+}
+
+func (dl *MemoryDenyList) DenyBefore(ctx context.Context, cutoff time.Time) error {
+	dl.m.Lock()
+	defer dl.m.Unlock()
+	if cutoff.After(dl.cutoff) {
+		dl.cutoff = cutoff
+	}
+	return nil
+}
+
+func (dl *MemoryDenyList) DeniedBefore(ctx context.Context) (time.Time, error) {
+	dl.m.Lock()
+	defer dl.m.Unlock()
+	return dl.cutoff, nil
+}
@@ -0,0 +1,268 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// hs256Key returns a HS256 key with a secret made of its id
+func hs256Key(id string) sessionmanager.JWTKey {
+	return sessionmanager.JWTKey{ID: id, Algorithm: sessionmanager.JWTHS256, Secret: []byte(strings.Repeat(id, 32))}
+}
+
+func TestJWTSessionManager_GetSession(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	cases := map[string]struct {
+		key sessionmanager.JWTKey
+	}{
+		"HS256": {
+			key: hs256Key("a"),
+		},
+
+		"EdDSA": {
+			key: sessionmanager.JWTKey{ID: "ed", Algorithm: sessionmanager.JWTEdDSA, PrivateKey: private},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm, err := sessionmanager.NewJWTSessionManager(sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{tc.key}, Issuer: "tests"})
+			assert.NoError(t, err)
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, s.SetMany(map[string]interface{}{"user": "solrac", "admin": true}))
+			token, err := s.(*sessionmanager.JWTSession).Token()
+			assert.NoError(t, err)
+
+			loaded, err := sm.GetSession(token)
+			assert.NoError(t, err)
+			assert.Equal(t, s.SessionId(), loaded.SessionId())
+			assert.Equal(t, s.All(), loaded.All())
+			assert.Equal(t, s.Metadata().ExpirationTime, loaded.Metadata().ExpirationTime)
+			assert.Equal(t, s.Metadata().CreatedAt, loaded.Metadata().CreatedAt)
+		})
+	}
+}
+
+func TestJWTSessionManager_InvalidToken(t *testing.T) {
+	cases := map[string]struct {
+		token func(token string) string
+		opts  sessionmanager.JWTOptions
+	}{
+		"malformed": {
+			token: func(token string) string { return "malformed" },
+		},
+
+		"tampered claims": {
+			token: func(token string) string {
+				parts := strings.Split(token, ".")
+				parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"jti":"other","exp":9999999999}`))
+				return strings.Join(parts, ".")
+			},
+		},
+
+		"algorithm none": {
+			token: func(token string) string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT","kid":"a"}`))
+				parts := strings.Split(token, ".")
+				return header + "." + parts[1] + "."
+			},
+		},
+
+		"unknown key": {
+			token: func(token string) string { return token },
+			opts:  sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{hs256Key("b")}},
+		},
+
+		"other issuer": {
+			token: func(token string) string { return token },
+			opts:  sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{hs256Key("a")}, Issuer: "other"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			issuer, err := sessionmanager.NewJWTSessionManager(sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{hs256Key("a")}})
+			assert.NoError(t, err)
+			s, _ := issuer.CreateSession()
+			token, err := s.(*sessionmanager.JWTSession).Token()
+			assert.NoError(t, err)
+
+			verifier := issuer
+			if tc.opts.Keys != nil {
+				verifier, err = sessionmanager.NewJWTSessionManager(tc.opts)
+				assert.NoError(t, err)
+			}
+			_, err = verifier.GetSession(tc.token(token))
+			assert.True(t, errors.Is(err, sessionmanager.ErrInvalidToken), "actual %v", err)
+		})
+	}
+}
+
+func TestJWTSessionManager_KeyRotation(t *testing.T) {
+	sm, err := sessionmanager.NewJWTSessionManager(sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{hs256Key("a")}})
+	assert.NoError(t, err)
+	s, _ := sm.CreateSession()
+	old, err := s.(*sessionmanager.JWTSession).Token()
+	assert.NoError(t, err)
+
+	assert.NoError(t, sm.SetKeys([]sessionmanager.JWTKey{hs256Key("a"), hs256Key("b")}, "b"))
+	_, err = sm.GetSession(old)
+	assert.NoError(t, err)
+	rotated, err := s.(*sessionmanager.JWTSession).Token()
+	assert.NoError(t, err)
+	header, _ := base64.RawURLEncoding.DecodeString(strings.Split(rotated, ".")[0])
+	assert.Contains(t, string(header), `"kid":"b"`)
+
+	assert.NoError(t, sm.SetKeys([]sessionmanager.JWTKey{hs256Key("b")}, ""))
+	_, err = sm.GetSession(old)
+	assert.True(t, errors.Is(err, sessionmanager.ErrInvalidToken))
+	_, err = sm.GetSession(rotated)
+	assert.NoError(t, err)
+
+	assert.Error(t, sm.SetKeys([]sessionmanager.JWTKey{{ID: "short", Algorithm: sessionmanager.JWTHS256, Secret: []byte("short")}}, ""))
+	assert.Error(t, sm.SetKeys([]sessionmanager.JWTKey{hs256Key("b")}, "missing"))
+}
+
+func TestJWTSessionManager_Lifecycle(t *testing.T) {
+	cases := map[string]struct {
+		ttl      time.Duration
+		denyList bool
+		action   func(sm *sessionmanager.JWTSessionManager, token string) error
+		err      error
+	}{
+		"destroyed": {
+			denyList: true,
+			action: func(sm *sessionmanager.JWTSessionManager, token string) error {
+				return sm.DestroySession(token)
+			},
+			err: sessionmanager.ErrSessionRevoked,
+		},
+
+		"destroyed without deny-list": {
+			action: func(sm *sessionmanager.JWTSessionManager, token string) error {
+				assert.Error(t, sm.DestroySession(token))
+				return nil
+			},
+		},
+
+		"all destroyed": {
+			action: func(sm *sessionmanager.JWTSessionManager, token string) error {
+				return sm.DestroyAllSessions()
+			},
+			err: sessionmanager.ErrSessionNotFound,
+		},
+
+		"expired": {
+			ttl: -time.Minute,
+			action: func(sm *sessionmanager.JWTSessionManager, token string) error {
+				return nil
+			},
+			err: sessionmanager.ErrSessionExpired,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts := sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{hs256Key("a")}, TTL: tc.ttl}
+			if tc.denyList {
+				opts.DenyList = sessionmanager.NewMemoryDenyList()
+			}
+			sm, err := sessionmanager.NewJWTSessionManager(opts)
+			assert.NoError(t, err)
+			s, _ := sm.CreateSession()
+			token, err := s.(*sessionmanager.JWTSession).Token()
+			assert.NoError(t, err)
+
+			assert.NoError(t, tc.action(sm, token))
+			_, err = sm.GetSession(token)
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tc.err), "expected %s, actual %v", tc.err, err)
+		})
+	}
+}
+
+func TestJWTSessionManager_DestroyAllSessions(t *testing.T) {
+	cases := map[string]struct {
+		denyList bool
+	}{
+		"without deny-list": {},
+
+		"shared deny-list": {
+			denyList: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			opts := sessionmanager.JWTOptions{Keys: []sessionmanager.JWTKey{hs256Key("a")}}
+			if tc.denyList {
+				opts.DenyList = sessionmanager.NewMemoryDenyList()
+			}
+			sm, err := sessionmanager.NewJWTSessionManager(opts)
+			assert.NoError(t, err)
+			other, err := sessionmanager.NewJWTSessionManager(opts)
+			assert.NoError(t, err)
+			s, _ := sm.CreateSession()
+			old, err := s.(*sessionmanager.JWTSession).Token()
+			assert.NoError(t, err)
+
+			// Sessions created in the same second as DestroyAllSessions, by any manager, are kept
+			assert.NoError(t, sm.DestroyAllSessions())
+			for _, creator := range []*sessionmanager.JWTSessionManager{sm, other} {
+				s, _ = creator.CreateSession()
+				token, err := s.(*sessionmanager.JWTSession).Token()
+				assert.NoError(t, err)
+				_, err = sm.GetSession(token)
+				assert.NoError(t, err)
+			}
+			_, err = sm.GetSession(old)
+			assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+
+			// The managers sharing the deny-list, or created later with it, reject the old tokens too
+			restarted, err := sessionmanager.NewJWTSessionManager(opts)
+			assert.NoError(t, err)
+			for _, verifier := range []*sessionmanager.JWTSessionManager{other, restarted} {
+				_, err = verifier.GetSession(old)
+				assert.Equal(t, tc.denyList, errors.Is(err, sessionmanager.ErrSessionNotFound))
+			}
+		})
+	}
+}
+
+func TestMemoryDenyList_Purge(t *testing.T) {
+	denyList := sessionmanager.NewMemoryDenyList()
+	sm, err := sessionmanager.NewJWTSessionManager(sessionmanager.JWTOptions{
+		Keys:     []sessionmanager.JWTKey{hs256Key("a")},
+		TTL:      -time.Minute,
+		DenyList: denyList,
+	})
+	assert.NoError(t, err)
+	s, _ := sm.CreateSession()
+	token, err := s.(*sessionmanager.JWTSession).Token()
+	assert.NoError(t, err)
+
+	// The entry of the expired token is purged by the next revocation
+	ctx := context.Background()
+	assert.NoError(t, denyList.Deny(ctx, s.SessionId(), sessionmanager.RevokedLogout, s.Metadata().ExpirationTime))
+	assert.NoError(t, denyList.Deny(ctx, "other", sessionmanager.RevokedLogout, time.Now().Add(time.Minute)))
+	_, denied, err := denyList.Denied(ctx, s.SessionId())
+	assert.NoError(t, err)
+	assert.False(t, denied)
+
+	_, err = sm.GetSession(token)
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionExpired))
+}
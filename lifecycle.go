@@ -0,0 +1,117 @@
+package sessionmanager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionExpired is wrapped by the errors returned for expired sessions
+var ErrSessionExpired = errors.New("session expired")
+
+// ErrSessionRevoked is wrapped by the errors returned for sessions invalidated with Invalidate
+var ErrSessionRevoked = errors.New("session revoked")
+
+// RevocationReason tells why a session was invalidated
+type RevocationReason string
+
+// Common revocation reasons, any other value can be used
+const (
+	RevokedLogout         RevocationReason = "logout"
+	RevokedByAdmin        RevocationReason = "admin_revoke"
+	RevokedPasswordChange RevocationReason = "password_change"
+	// RevokedFingerprintMismatch is set by the BindReauthenticate binding action
+	RevokedFingerprintMismatch RevocationReason = "fingerprint_mismatch"
+	// RevokedTokenTheft is set on the sessions of a remember-me series when one of its tokens is reused
+	RevokedTokenTheft RevocationReason = "token_theft"
+)
+
+// RevokedError is returned by GetSession for a session invalidated with Invalidate
+type RevokedError struct {
+	SessionId string
+	Reason    RevocationReason
+	RevokedAt time.Time
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("Session ID %s is revoked: %s", e.SessionId, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrSessionRevoked) true for a *RevokedError
+func (e *RevokedError) Is(target error) bool {
+	return target == ErrSessionRevoked
+}
+
+// sessionError keeps the message of the session manager errors while
+// letting callers tell them apart with errors.Is
+type sessionError struct {
+	msg string
+	err error
+}
+
+func (e *sessionError) Error() string {
+	return e.msg
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.err
+}
+
+// notFoundError returns the error of a session that does not exist
+func notFoundError(sessionId string) error {
+	return &sessionError{msg: fmt.Sprintf("Session ID %s not found", sessionId), err: ErrSessionNotFound}
+}
+
+// expiredError returns the error of an expired session
+func expiredError(sessionId string) error {
+	return &sessionError{msg: fmt.Sprintf("Session ID %s is expired", sessionId), err: ErrSessionExpired}
+}
+
+// Invalidate deactivates the session before it expires recording why and when,
+// the session manager reports it as revoked until it is reactivated
+func (s *Session) Invalidate(reason RevocationReason) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	revokedAt := time.Now()
+	if err := s.notify(sessionChange{Kind: changeRevoke, Reason: reason, RevokedAt: revokedAt}); err != nil {
+		return err
+	}
+	s.Active = false
+	s.RevokedReason = reason
+	s.RevokedAt = revokedAt
+	s.activeChanged = true
+	return nil
+}
+
+// Reactivate makes an invalidated or expired session active again
+//   - The expiration time is not changed, extend it with SetExpirationTime if it already passed
+func (s *Session) Reactivate() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if err := s.notify(sessionChange{Kind: changeReactivate}); err != nil {
+		return err
+	}
+	s.Active = true
+	s.Expired = false
+	s.RevokedReason = ""
+	s.RevokedAt = time.Time{}
+	s.activeChanged = true
+	return nil
+}
+
+// IsRevoked returns true if the session was invalidated and not reactivated
+func (s *Session) IsRevoked() bool {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return !s.RevokedAt.IsZero()
+}
+
+// revokedError returns a *RevokedError if the session was invalidated or nil otherwise
+func (s *Session) revokedError() error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	if s.RevokedAt.IsZero() {
+		return nil
+	}
+	return &RevokedError{SessionId: s.ID, Reason: s.RevokedReason, RevokedAt: s.RevokedAt}
+}
@@ -0,0 +1,111 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_GetSession_Lifecycle(t *testing.T) {
+	cases := map[string]struct {
+		action func(s sessionmanager.ISession) string
+		err    error
+	}{
+		"not found": {
+			action: func(s sessionmanager.ISession) string { return "missing" },
+			err:    sessionmanager.ErrSessionNotFound,
+		},
+
+		"expired": {
+			action: func(s sessionmanager.ISession) string {
+				s.SetExpirationTime(time.Now().Add(-time.Minute))
+				return s.SessionId()
+			},
+			err: sessionmanager.ErrSessionExpired,
+		},
+
+		"revoked": {
+			action: func(s sessionmanager.ISession) string {
+				s.Invalidate(sessionmanager.RevokedPasswordChange)
+				return s.SessionId()
+			},
+			err: sessionmanager.ErrSessionRevoked,
+		},
+
+		"reactivated": {
+			action: func(s sessionmanager.ISession) string {
+				s.Invalidate(sessionmanager.RevokedByAdmin)
+				s.Reactivate()
+				return s.SessionId()
+			},
+		},
+
+		"reactivated after expiring": {
+			action: func(s sessionmanager.ISession) string {
+				s.SetExpirationTime(time.Now().Add(-time.Minute))
+				s.IsExpired()
+				s.SetExpirationTime(time.Now().Add(time.Hour))
+				s.Reactivate()
+				return s.SessionId()
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetAvoidExpired(true)
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+
+			_, err = sm.GetSession(tc.action(s))
+			if tc.err == nil {
+				assert.NoError(t, err)
+				assert.True(t, s.IsActive())
+				return
+			}
+			assert.True(t, errors.Is(err, tc.err), "expected %s, actual %v", tc.err, err)
+			for _, other := range []error{sessionmanager.ErrSessionNotFound, sessionmanager.ErrSessionExpired, sessionmanager.ErrSessionRevoked} {
+				if other != tc.err {
+					assert.False(t, errors.Is(err, other))
+				}
+			}
+
+			var revoked *sessionmanager.RevokedError
+			if errors.As(err, &revoked) {
+				assert.Equal(t, sessionmanager.RevokedPasswordChange, revoked.Reason)
+				assert.False(t, revoked.RevokedAt.IsZero())
+			}
+		})
+	}
+}
+
+func TestStore_Revocation(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			s := sessionmanager.NewSession(map[string]interface{}{"user": "solrac"})
+			assert.NoError(t, s.Invalidate(sessionmanager.RevokedLogout))
+			assert.NoError(t, store.Save(ctx, s))
+
+			loaded, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			assert.True(t, loaded.IsRevoked())
+			assert.False(t, loaded.IsActive())
+			assert.Equal(t, sessionmanager.RevokedLogout, loaded.RevokedReason)
+			assert.WithinDuration(t, s.RevokedAt, loaded.RevokedAt, time.Millisecond)
+
+			assert.NoError(t, loaded.Reactivate())
+			assert.NoError(t, store.Save(ctx, loaded))
+			loaded, err = store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			assert.False(t, loaded.IsRevoked())
+			assert.True(t, loaded.IsActive())
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package sessionmanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+)
+
+// HashSessionID returns a short hash of a session id that can be written to logs
+// and traces in place of the id, the id itself is a credential and must not be logged
+func HashSessionID(sessionId string) string {
+	sum := sha256.Sum256([]byte(sessionId))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SetLogger sets the logger for the session lifecycle records
+//   - By default the session manager does not log anything
+//   - Session ids are never logged, the records contain the HashSessionID of them
+func (sm *SessionManager) SetLogger(logger *slog.Logger) {
+	sm.logger.Store(logger)
+}
+
+// log writes a record for the session if a logger was set, records
+// not related to a single session are written with an empty session id
+func (sm *SessionManager) log(level slog.Level, msg string, sessionId string, attrs ...slog.Attr) {
+	logger := sm.logger.Load()
+	if logger == nil {
+		return
+	}
+	if sessionId != "" {
+		attrs = append(attrs, slog.String("session", HashSessionID(sessionId)))
+	}
+	logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
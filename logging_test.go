@@ -0,0 +1,85 @@
+package sessionmanager_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashSessionID(t *testing.T) {
+	cases := map[string]struct {
+		sessionId string
+	}{
+		"uuid": {
+			sessionId: "0b2b1a36-3a6e-4d8e-9d6c-1b0c7f7e5e2a",
+		},
+		"empty": {
+			sessionId: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hash := sessionmanager.HashSessionID(tc.sessionId)
+
+			assert.Len(t, hash, 16)
+			assert.Equal(t, hash, sessionmanager.HashSessionID(tc.sessionId))
+			assert.NotEqual(t, tc.sessionId, hash)
+		})
+	}
+}
+
+func TestSessionManager_SetLogger(t *testing.T) {
+	cases := map[string]struct {
+		action   func(sm *sessionmanager.SessionManager, s sessionmanager.ISession)
+		expected []string
+	}{
+		"create": {
+			action:   func(sm *sessionmanager.SessionManager, s sessionmanager.ISession) {},
+			expected: []string{"msg=\"session created\""},
+		},
+
+		"destroy": {
+			action: func(sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.DestroySession(s.SessionId())
+			},
+			expected: []string{"msg=\"session destroyed\""},
+		},
+
+		"expire": {
+			action: func(sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.SetAvoidExpired(true)
+				s.SetExpirationTime(time.Now().Add(-1 * time.Minute))
+				sm.GetSession(s.SessionId())
+			},
+			expected: []string{"msg=\"session expired\"", "msg=\"expired session requested\""},
+		},
+
+		"not found": {
+			action: func(sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.GetSession("unknown")
+			},
+			expected: []string{"msg=\"session not found\" session=" + sessionmanager.HashSessionID("unknown")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			sessionManager := sessionmanager.NewSessionManager()
+			sessionManager.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+			s, _ := sessionManager.CreateSession()
+			tc.action(sessionManager, s)
+
+			for _, line := range tc.expected {
+				assert.Contains(t, buf.String(), line)
+			}
+			assert.NotContains(t, buf.String(), s.SessionId())
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package sessionmanager
+
+import "time"
+
+// ClientInfo describes the client using a session
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// Device is a label chosen by the application, like "Chrome on Windows"
+	Device string
+}
+
+// Metadata is a copy of the information about how a session is used, meant for
+// pages listing the active sessions or devices of a user
+type Metadata struct {
+	SessionId      string
+	CreatedAt      time.Time
+	LastAccessedAt time.Time
+	AccessCount    uint64
+	ExpirationTime time.Time
+	Client         ClientInfo
+}
+
+// Metadata returns how the session is used
+func (s *Session) Metadata() Metadata {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return Metadata{
+		SessionId:      s.ID,
+		CreatedAt:      s.CreatedAt,
+		LastAccessedAt: s.LastAccessedAt,
+		AccessCount:    s.AccessCount,
+		ExpirationTime: s.ExpirationTime,
+		Client:         s.Client,
+	}
+}
+
+// SetClient records the client using the session
+func (s *Session) SetClient(client ClientInfo) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if client == s.Client {
+		return nil
+	}
+	if err := s.notify(sessionChange{Kind: changeClient, Client: client}); err != nil {
+		return err
+	}
+	s.Client = client
+	return nil
+}
+
+// defaultAccessSaveInterval is how often the accesses to a session are persisted by default
+const defaultAccessSaveInterval = time.Minute
+
+// SetAccessSaveInterval sets how often the accesses to a session make it dirty,
+// so SaveSession persists its last access and access count
+//   - By default an access is persisted once a minute, so reading a session does not write it on every request
+//   - Accesses within the interval of the last persisted one are only written with other changes
+//   - Zero persists every access
+func (sm *SessionManager) SetAccessSaveInterval(interval time.Duration) {
+	sm.accessSaveInterval.Store(int64(interval))
+}
+
+// touch records an access to the session, it makes the session dirty if the
+// last persisted access is older than interval
+func (s *Session) touch(now time.Time, interval time.Duration) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.LastAccessedAt = now
+	s.AccessCount++
+	if now.Sub(s.accessSavedAt) >= interval {
+		s.accessChanged = true
+	}
+}
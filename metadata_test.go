@@ -0,0 +1,95 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_Metadata(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	created, err := sm.CreateSession()
+	assert.NoError(t, err)
+	s := created.(*sessionmanager.Session)
+
+	metadata := s.Metadata()
+	assert.Equal(t, s.SessionId(), metadata.SessionId)
+	assert.WithinDuration(t, time.Now(), metadata.CreatedAt, time.Second)
+	assert.Equal(t, metadata.CreatedAt, metadata.LastAccessedAt)
+	assert.Equal(t, uint64(0), metadata.AccessCount)
+
+	for i := 0; i < 3; i++ {
+		_, err = sm.GetSession(s.SessionId())
+		assert.NoError(t, err)
+	}
+	metadata = s.Metadata()
+	assert.Equal(t, uint64(3), metadata.AccessCount)
+	assert.False(t, metadata.LastAccessedAt.Before(metadata.CreatedAt))
+	assert.True(t, s.Changes().Access)
+
+	client := sessionmanager.ClientInfo{IP: "10.0.0.1", UserAgent: "curl/8.0", Device: "CLI"}
+	assert.NoError(t, s.SetClient(client))
+	assert.Equal(t, client, s.Metadata().Client)
+	assert.True(t, s.Changes().Client)
+}
+
+func TestSessionManager_SetAccessSaveInterval(t *testing.T) {
+	cases := map[string]struct {
+		interval time.Duration
+		expected uint64
+	}{
+		"every access persisted": {
+			expected: 6,
+		},
+
+		"accesses within the interval not persisted": {
+			interval: time.Hour,
+			expected: 0,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store := newBoltStore(t)
+			sm := sessionmanager.NewSessionManager()
+			sm.SetStore(store)
+			sm.SetAccessSaveInterval(tc.interval)
+			created, err := sm.CreateSession()
+			assert.NoError(t, err)
+
+			for i := 0; i < 6; i++ {
+				s, err := sm.GetSession(created.SessionId())
+				assert.NoError(t, err)
+				assert.NoError(t, sm.SaveSession(s))
+			}
+			loaded, err := store.Load(context.Background(), created.SessionId())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, loaded.Metadata().AccessCount)
+		})
+	}
+}
+
+func TestStore_Metadata(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			s := sessionmanager.NewSession(nil)
+			assert.NoError(t, store.Save(ctx, s))
+
+			client := sessionmanager.ClientInfo{IP: "2001:db8::1", UserAgent: "Mozilla/5.0", Device: "Firefox on Linux"}
+			assert.NoError(t, s.SetClient(client))
+			assert.NoError(t, store.Save(ctx, s))
+
+			loaded, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			metadata := loaded.Metadata()
+			assert.Equal(t, client, metadata.Client)
+			assert.WithinDuration(t, s.CreatedAt, metadata.CreatedAt, time.Millisecond)
+			assert.WithinDuration(t, s.LastAccessedAt, metadata.LastAccessedAt, time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package sessionmanager
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operations measured by the latency histograms of Metrics
+const (
+	OperationGet     = "get"
+	OperationCreate  = "create"
+	OperationDestroy = "destroy"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds used by the latency histograms
+var DefaultLatencyBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.05, 0.1,
+}
+
+// Metrics collects the statistics of a session manager
+//   - Counters are updated with atomic operations, so collecting them is cheap
+//   - Gauges are calculated from the stored sessions when they are requested
+type Metrics struct {
+	created               atomic.Uint64
+	destroyed             atomic.Uint64
+	expired               atomic.Uint64
+	evicted               atomic.Uint64
+	rejected              atomic.Uint64
+	fingerprintMismatches atomic.Uint64
+	lookupHits            atomic.Uint64
+	lookupMisses          atomic.Uint64
+	latencies             map[string]*histogram
+}
+
+// SessionStats is a point in time copy of the session manager statistics
+type SessionStats struct {
+	Created               uint64
+	Destroyed             uint64
+	Expired               uint64
+	Evicted               uint64
+	Rejected              uint64
+	FingerprintMismatches uint64
+	LookupHits            uint64
+	LookupMisses          uint64
+	ActiveSessions        int
+	DataKeys              int
+}
+
+// newMetrics is the constructor for metrics with a latency histogram per operation
+func newMetrics() *Metrics {
+	return &Metrics{
+		latencies: map[string]*histogram{
+			OperationGet:     newHistogram(DefaultLatencyBuckets),
+			OperationCreate:  newHistogram(DefaultLatencyBuckets),
+			OperationDestroy: newHistogram(DefaultLatencyBuckets),
+		},
+	}
+}
+
+// observe records the duration of an operation started at start
+func (m *Metrics) observe(operation string, start time.Time) {
+	if h, ok := m.latencies[operation]; ok {
+		h.observe(time.Since(start).Seconds())
+	}
+}
+
+// Stats returns the current statistics of the session manager
+func (sm *SessionManager) Stats() SessionStats {
+	stats := SessionStats{
+		Created:               sm.metrics.created.Load(),
+		Destroyed:             sm.metrics.destroyed.Load(),
+		Evicted:               sm.metrics.evicted.Load(),
+		Rejected:              sm.metrics.rejected.Load(),
+		FingerprintMismatches: sm.metrics.fingerprintMismatches.Load(),
+		LookupHits:            sm.metrics.lookupHits.Load(),
+		LookupMisses:          sm.metrics.lookupMisses.Load(),
+	}
+
+	sm.m.RLock()
+	for _, session := range sm.Sessions {
+		if !session.IsExpired() && session.IsActive() {
+			stats.ActiveSessions++
+		}
+		stats.DataKeys += session.Len()
+	}
+	sm.m.RUnlock()
+
+	// Read after the gauges because checking the sessions can expire some of them
+	stats.Expired = sm.metrics.expired.Load()
+	return stats
+}
+
+// MetricsHandler returns a http.Handler that exposes the session manager
+// statistics in the Prometheus text exposition format
+func (sm *SessionManager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		sm.WriteMetrics(w)
+	})
+}
+
+// WriteMetrics writes the session manager statistics in the Prometheus text exposition format
+func (sm *SessionManager) WriteMetrics(w io.Writer) error {
+	stats := sm.Stats()
+
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value interface{}
+	}{
+		{"session_manager_sessions_created_total", "Total number of sessions created.", "counter", stats.Created},
+		{"session_manager_sessions_destroyed_total", "Total number of sessions destroyed.", "counter", stats.Destroyed},
+		{"session_manager_sessions_expired_total", "Total number of sessions detected as expired.", "counter", stats.Expired},
+		{"session_manager_sessions_evicted_total", "Total number of sessions evicted because the maximum number of sessions was reached.", "counter", stats.Evicted},
+		{"session_manager_sessions_rejected_total", "Total number of sessions rejected because the maximum number of sessions was reached.", "counter", stats.Rejected},
+		{"session_manager_fingerprint_mismatches_total", "Total number of requests from clients that did not match the fingerprint of their session.", "counter", stats.FingerprintMismatches},
+		{"session_manager_lookup_hits_total", "Total number of session lookups that returned a session.", "counter", stats.LookupHits},
+		{"session_manager_lookup_misses_total", "Total number of session lookups that did not return a session.", "counter", stats.LookupMisses},
+		{"session_manager_active_sessions", "Number of active sessions stored.", "gauge", stats.ActiveSessions},
+		{"session_manager_data_keys", "Number of data keys stored across all sessions.", "gauge", stats.DataKeys},
+	}
+
+	for _, metric := range metrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	const latencyName = "session_manager_operation_duration_seconds"
+	_, err := fmt.Fprintf(w, "# HELP %s Latency of the session manager operations.\n# TYPE %s histogram\n", latencyName, latencyName)
+	if err != nil {
+		return err
+	}
+
+	operations := make([]string, 0, len(sm.metrics.latencies))
+	for operation := range sm.metrics.latencies {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	for _, operation := range operations {
+		if err := sm.metrics.latencies[operation].write(w, latencyName, operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// histogram is a cumulative histogram with fixed buckets
+type histogram struct {
+	m       sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// newHistogram is the constructor for histogram, buckets must be sorted
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// observe adds a value to the histogram
+func (h *histogram) observe(value float64) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// write writes the histogram series labeled with the operation
+func (h *histogram) write(w io.Writer, name string, operation string) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for i, bound := range h.buckets {
+		_, err := fmt.Fprintf(w, "%s_bucket{operation=%q,le=\"%g\"} %d\n", name, operation, bound, h.counts[i])
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{operation=%q,le=\"+Inf\"} %d\n%s_sum{operation=%q} %g\n%s_count{operation=%q} %d\n",
+		name, operation, h.count, name, operation, h.sum, name, operation, h.count)
+	return err
+}
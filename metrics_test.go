@@ -0,0 +1,117 @@
+package sessionmanager_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_Stats(t *testing.T) {
+	cases := map[string]struct {
+		setup    func(sm *sessionmanager.SessionManager)
+		expected sessionmanager.SessionStats
+	}{
+		"empty": {
+			setup:    func(sm *sessionmanager.SessionManager) {},
+			expected: sessionmanager.SessionStats{},
+		},
+
+		"created and destroyed": {
+			setup: func(sm *sessionmanager.SessionManager) {
+				s, _ := sm.CreateSession()
+				s.Set("key", "value")
+				sm.CreateSession()
+				sm.DestroySession(s.SessionId())
+			},
+			expected: sessionmanager.SessionStats{Created: 2, Destroyed: 1, ActiveSessions: 1},
+		},
+
+		"lookup hits and misses": {
+			setup: func(sm *sessionmanager.SessionManager) {
+				s, _ := sm.CreateSession()
+				s.Set("key", "value")
+				sm.GetSession(s.SessionId())
+				sm.GetSession("unknown")
+			},
+			expected: sessionmanager.SessionStats{Created: 1, LookupHits: 1, LookupMisses: 1, ActiveSessions: 1, DataKeys: 1},
+		},
+
+		"expired": {
+			setup: func(sm *sessionmanager.SessionManager) {
+				sm.SetAvoidExpired(true)
+				s, _ := sm.CreateSession()
+				s.SetExpirationTime(time.Now().Add(-1 * time.Minute))
+				sm.GetSession(s.SessionId())
+				sm.GetSession(s.SessionId())
+			},
+			expected: sessionmanager.SessionStats{Created: 1, Expired: 1, LookupMisses: 2},
+		},
+
+		"destroy all": {
+			setup: func(sm *sessionmanager.SessionManager) {
+				sm.CreateSession()
+				sm.CreateSession()
+				sm.DestroyAllSessions()
+			},
+			expected: sessionmanager.SessionStats{Created: 2, Destroyed: 2},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sessionManager := sessionmanager.NewSessionManager()
+			tc.setup(sessionManager)
+
+			assert.Equal(t, tc.expected, sessionManager.Stats())
+		})
+	}
+}
+
+func TestSessionManager_MetricsHandler(t *testing.T) {
+	cases := map[string]struct {
+		expected []string
+	}{
+		"counters and gauges": {
+			expected: []string{
+				"# TYPE session_manager_sessions_created_total counter",
+				"session_manager_sessions_created_total 1",
+				"session_manager_lookup_hits_total 1",
+				"session_manager_lookup_misses_total 0",
+				"# TYPE session_manager_active_sessions gauge",
+				"session_manager_active_sessions 1",
+				"session_manager_data_keys 0",
+			},
+		},
+
+		"histograms": {
+			expected: []string{
+				"# TYPE session_manager_operation_duration_seconds histogram",
+				`session_manager_operation_duration_seconds_bucket{operation="create",le="+Inf"} 1`,
+				`session_manager_operation_duration_seconds_count{operation="get"} 1`,
+				`session_manager_operation_duration_seconds_count{operation="destroy"} 0`,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sessionManager := sessionmanager.NewSessionManager()
+			s, _ := sessionManager.CreateSession()
+			sessionManager.GetSession(s.SessionId())
+
+			recorder := httptest.NewRecorder()
+			sessionManager.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+			for _, line := range tc.expected {
+				assert.Contains(t, recorder.Body.String(), line+"\n")
+			}
+		})
+	}
+}
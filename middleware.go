@@ -0,0 +1,250 @@
+package sessionmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MiddlewareOptions configures the http middleware of a session manager
+type MiddlewareOptions struct {
+	// CookieName is the cookie holding the session id, by default "session_id"
+	CookieName string
+	// CookiePath is the path of the cookie, by default "/"
+	CookiePath string
+	// CookieDomain is the domain of the cookie, by default the host of the request
+	CookieDomain string
+	// Secure sends the cookie only over https
+	Secure bool
+	// SameSite is the same site policy of the cookie, by default http.SameSiteLaxMode
+	SameSite http.SameSite
+	// ClientIP returns the ip of the client, by default the host of the request remote address,
+	// set it when the application is behind a proxy
+	ClientIP func(r *http.Request) string
+	// Device returns a label of the client device, by default none
+	Device func(r *http.Request) string
+	// RememberMe logs in again the clients without a valid session that present a remember-me token
+	RememberMe *RememberMe
+}
+
+// sessionContextKey is the key of the session in the request context
+type sessionContextKey struct{}
+
+// requestSession is the session of a request served by the middleware, it is
+// replaced when the handler regenerates the session with the request context
+type requestSession struct {
+	m       sync.Mutex
+	session ISession
+	w       http.ResponseWriter
+	opts    MiddlewareOptions
+}
+
+// current returns the session of the request
+func (rs *requestSession) current() ISession {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	return rs.session
+}
+
+// regenerated replaces the session of the request and its cookie if it is the old session
+func (rs *requestSession) regenerated(oldId string, regenerated ISession) {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	if rs.session.SessionId() != oldId {
+		return
+	}
+	rs.session = regenerated
+	replaceCookie(rs.w, rs.opts.cookie(regenerated))
+}
+
+// mismatchContextKey is the key of the fingerprint mismatch in the request context
+type mismatchContextKey struct{}
+
+// Middleware returns a net/http middleware that loads the session of the
+// request cookie, or creates a new one, and stores it in the request context
+//   - Sessions record the client ip, user agent and device of the last request
+//   - Sessions not found, expired or revoked are replaced with a new session, other
+//     errors loading the session are logged and answered with 500 Internal Server Error
+//   - With a binding policy new sessions are bound to the client fingerprint and the
+//     existing ones are checked, BindReject answers 403 Forbidden, BindReauthenticate
+//     replaces the session and both BindFlag and BindReauthenticate report the mismatch
+//     with FingerprintMismatch
+//   - With RememberMe the clients without a valid session are logged in again from their
+//     remember-me cookie, which is rotated, or deleted if the token is not valid
+//   - With a store the session is saved after the handler if it changed
+//   - A handler regenerating the session with RegenerateSessionContext and the request
+//     context gets the regenerated session from the context, and its cookie is sent
+//     instead, as long as the handler did not write the response yet
+func (sm *SessionManager) Middleware(opts MiddlewareOptions) func(http.Handler) http.Handler {
+	if opts.CookieName == "" {
+		opts.CookieName = "session_id"
+	}
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if opts.ClientIP == nil {
+		opts.ClientIP = remoteIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			session, mismatch, err := sm.requestSession(ctx, w, r, opts)
+			if errors.Is(err, ErrFingerprintMismatch) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				sm.log(slog.LevelError, "session middleware failed", "", slog.String("error", err.Error()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, opts.cookie(session))
+
+			rs := &requestSession{session: session, w: w, opts: opts}
+			handlerCtx := context.WithValue(ctx, sessionContextKey{}, rs)
+			if mismatch != nil {
+				handlerCtx = context.WithValue(handlerCtx, mismatchContextKey{}, mismatch)
+			}
+			next.ServeHTTP(w, r.WithContext(handlerCtx))
+
+			// Concurrent requests of the same session are merged, so a request that only
+			// recorded its access does not make the changes of another one conflict
+			session = rs.current()
+			if err := sm.SaveSessionMerge(ctx, session, 3); err != nil {
+				sm.log(slog.LevelError, "session middleware save failed", session.SessionId(), slog.String("error", err.Error()))
+			}
+		})
+	}
+}
+
+// SessionFromContext returns the session stored in the context by the middleware
+func SessionFromContext(ctx context.Context) (ISession, bool) {
+	switch session := ctx.Value(sessionContextKey{}).(type) {
+	case *requestSession:
+		return session.current(), true
+	case ISession:
+		return session, true
+	}
+	return nil, false
+}
+
+// FingerprintMismatch returns the fingerprint mismatch of the request session
+// reported by the middleware when the binding action is BindFlag or BindReauthenticate
+func FingerprintMismatch(ctx context.Context) (*FingerprintError, bool) {
+	mismatch, ok := ctx.Value(mismatchContextKey{}).(*FingerprintError)
+	return mismatch, ok
+}
+
+// requestSession returns the session of the request cookie or a new session,
+// and the fingerprint mismatch of the request session if any
+func (sm *SessionManager) requestSession(ctx context.Context, w http.ResponseWriter, r *http.Request, opts MiddlewareOptions) (ISession, *FingerprintError, error) {
+	client := ClientInfo{IP: opts.ClientIP(r), UserAgent: r.UserAgent()}
+	if opts.Device != nil {
+		client.Device = opts.Device(r)
+	}
+	fingerprint := NewFingerprint(client.IP, client.UserAgent, peerCertificate(r))
+
+	var session ISession
+	var mismatch *FingerprintError
+	if cookie, err := r.Cookie(opts.CookieName); err == nil && cookie.Value != "" {
+		session, err = sm.GetSessionContext(ctx, cookie.Value)
+		// Only a session that is gone is replaced, the other errors would log the user out
+		if err != nil && !errors.Is(err, ErrSessionNotFound) && !errors.Is(err, ErrSessionExpired) && !errors.Is(err, ErrSessionRevoked) {
+			return nil, nil, err
+		}
+	}
+	// Expired sessions are replaced even if the session manager does not avoid them
+	if session != nil && session.IsExpired() {
+		session = nil
+	}
+	if session != nil {
+		err := sm.CheckFingerprint(ctx, session, fingerprint)
+		switch {
+		case errors.As(err, &mismatch):
+			if mismatch.Action == BindReject {
+				return nil, nil, err
+			}
+			if mismatch.Action == BindReauthenticate {
+				session = nil
+			}
+		case err != nil:
+			return nil, nil, err
+		}
+	}
+	if session == nil {
+		var created ISession
+		if opts.RememberMe != nil && mismatch == nil {
+			created = opts.RememberMe.requestLogin(ctx, w, r)
+		}
+		if created == nil {
+			var err error
+			if created, err = sm.CreateSessionContext(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+		if s, ok := created.(*Session); ok && sm.binding.Load() != nil {
+			if err := s.Bind(fingerprint); err != nil {
+				return nil, nil, err
+			}
+		}
+		session = created
+	}
+
+	if err := session.SetClient(client); err != nil {
+		return nil, nil, err
+	}
+	return session, mismatch, nil
+}
+
+// peerCertificate returns the TLS client certificate of the request or nil
+func peerCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
+
+// cookie returns the cookie holding the session id
+func (opts MiddlewareOptions) cookie(session ISession) *http.Cookie {
+	return &http.Cookie{
+		Name:     opts.CookieName,
+		Value:    session.SessionId(),
+		Path:     opts.CookiePath,
+		Domain:   opts.CookieDomain,
+		Expires:  session.Metadata().ExpirationTime,
+		Secure:   opts.Secure,
+		HttpOnly: true,
+		SameSite: opts.SameSite,
+	}
+}
+
+// replaceCookie sets a cookie removing the cookie with the same name set before
+func replaceCookie(w http.ResponseWriter, cookie *http.Cookie) {
+	header := w.Header()
+	values := header.Values("Set-Cookie")
+	header.Del("Set-Cookie")
+	for _, value := range values {
+		if !strings.HasPrefix(value, cookie.Name+"=") {
+			header.Add("Set-Cookie", value)
+		}
+	}
+	http.SetCookie(w, cookie)
+}
+
+// remoteIP returns the host of the request remote address
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,167 @@
+package sessionmanager_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_Middleware(t *testing.T) {
+	cases := map[string]struct {
+		cookie  func(sm *sessionmanager.SessionManager) string
+		created bool
+	}{
+		"no cookie": {
+			cookie:  func(sm *sessionmanager.SessionManager) string { return "" },
+			created: true,
+		},
+
+		"unknown session": {
+			cookie:  func(sm *sessionmanager.SessionManager) string { return "missing" },
+			created: true,
+		},
+
+		"revoked session": {
+			cookie: func(sm *sessionmanager.SessionManager) string {
+				s, _ := sm.CreateSession()
+				s.Invalidate(sessionmanager.RevokedLogout)
+				return s.SessionId()
+			},
+			created: true,
+		},
+
+		"expired session": {
+			cookie: func(sm *sessionmanager.SessionManager) string {
+				s, _ := sm.CreateSession()
+				s.SetExpirationTime(time.Now().Add(-time.Minute))
+				return s.SessionId()
+			},
+			created: true,
+		},
+
+		"existing session": {
+			cookie: func(sm *sessionmanager.SessionManager) string {
+				s, _ := sm.CreateSession()
+				return s.SessionId()
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sessionId := tc.cookie(sm)
+
+			var handled sessionmanager.ISession
+			handler := sm.Middleware(sessionmanager.MiddlewareOptions{
+				Device: func(r *http.Request) string { return "test device" },
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				s, ok := sessionmanager.SessionFromContext(r.Context())
+				assert.True(t, ok)
+				handled = s
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "192.0.2.7:51234"
+			r.Header.Set("User-Agent", "test agent")
+			if sessionId != "" {
+				r.AddCookie(&http.Cookie{Name: "session_id", Value: sessionId})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.NotNil(t, handled)
+			assert.Equal(t, tc.created, handled.SessionId() != sessionId)
+			assert.Equal(t, sessionmanager.ClientInfo{IP: "192.0.2.7", UserAgent: "test agent", Device: "test device"}, handled.Metadata().Client)
+
+			cookies := w.Result().Cookies()
+			assert.Len(t, cookies, 1)
+			assert.Equal(t, handled.SessionId(), cookies[0].Value)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+			assert.WithinDuration(t, handled.Metadata().ExpirationTime, cookies[0].Expires, time.Second)
+		})
+	}
+}
+
+// unavailableStore is a store that fails every operation
+type unavailableStore struct{}
+
+func (unavailableStore) Load(ctx context.Context, sessionId string) (*sessionmanager.Session, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (unavailableStore) Save(ctx context.Context, s *sessionmanager.Session) error {
+	return errors.New("store unavailable")
+}
+
+func (unavailableStore) Delete(ctx context.Context, sessionId string) error {
+	return errors.New("store unavailable")
+}
+
+func TestSessionManager_Middleware_StoreFailure(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	sm.SetStore(unavailableStore{})
+	handler := sm.Middleware(sessionmanager.MiddlewareOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without a session")
+	}))
+
+	// The cookie is kept, so the user is not logged out by a store outage
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: "6f1c"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestSessionManager_Middleware_Regenerate(t *testing.T) {
+	var logs bytes.Buffer
+	store := newBoltStore(t)
+	sm := sessionmanager.NewSessionManager()
+	sm.SetStore(store)
+	sm.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	old, err := sm.CreateSession()
+	assert.NoError(t, err)
+
+	var regenerated sessionmanager.ISession
+	handler := sm.Middleware(sessionmanager.MiddlewareOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := sessionmanager.SessionFromContext(r.Context())
+		regenerated, err = sm.RegenerateSessionContext(r.Context(), s.SessionId())
+		assert.NoError(t, err)
+		s, _ = sessionmanager.SessionFromContext(r.Context())
+		assert.Equal(t, regenerated.SessionId(), s.SessionId())
+		s.Set("user", "solrac")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: old.SessionId()})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// Only the cookie of the regenerated session is sent, and it is saved after the handler
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, regenerated.SessionId(), cookies[0].Value)
+	stored, err := store.Load(context.Background(), regenerated.SessionId())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "solrac"}, stored.All())
+	_, err = store.Load(context.Background(), old.SessionId())
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+	assert.NotContains(t, logs.String(), "level=ERROR")
+}
+
+func TestSessionFromContext_Missing(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	s, ok := sessionmanager.SessionFromContext(r.Context())
+	assert.False(t, ok)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,169 @@
+package sessionmanager
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// NamespaceSeparator separates the name of a namespace from the keys stored in it
+const NamespaceSeparator = ":"
+
+// Namespace is a view of a session whose keys are scoped to a prefix, so
+// several modules can share a session without colliding on key names
+//   - Keys are stored in the session as "<name>:<key>"
+//   - The keys of nested namespaces are part of their parent namespace
+type Namespace struct {
+	session *Session
+	prefix  string
+}
+
+// Verify that Namespace implements ISession
+var _ ISession = (*Namespace)(nil)
+
+// Namespace returns a view of the session scoped to name
+func (s *Session) Namespace(name string) *Namespace {
+	return &Namespace{session: s, prefix: name + NamespaceSeparator}
+}
+
+// Namespace returns a view nested in the namespace
+func (n *Namespace) Namespace(name string) *Namespace {
+	return &Namespace{session: n.session, prefix: n.prefix + name + NamespaceSeparator}
+}
+
+// Name returns the prefix of the namespace without the trailing separator
+func (n *Namespace) Name() string {
+	return strings.TrimSuffix(n.prefix, NamespaceSeparator)
+}
+
+// Get a value from the namespace
+func (n *Namespace) Get(key string) (interface{}, error) {
+	return n.session.Get(n.prefix + key)
+}
+
+// Set a value to the namespace
+func (n *Namespace) Set(key string, value interface{}) error {
+	return n.session.Set(n.prefix+key, value)
+}
+
+// Delete a value from the namespace
+func (n *Namespace) Delete(key string) error {
+	return n.session.Delete(n.prefix + key)
+}
+
+// SessionId returns the id of the session
+func (n *Namespace) SessionId() string {
+	return n.session.SessionId()
+}
+
+// SetExpirationTime sets the expiration time of the whole session
+func (n *Namespace) SetExpirationTime(expirationTime time.Time) {
+	n.session.SetExpirationTime(expirationTime)
+}
+
+// IsExpired returns true if the session is expired
+func (n *Namespace) IsExpired() bool {
+	return n.session.IsExpired()
+}
+
+// IsActive returns true if the session is active
+func (n *Namespace) IsActive() bool {
+	return n.session.IsActive()
+}
+
+// Invalidate deactivates the whole session
+func (n *Namespace) Invalidate(reason RevocationReason) error {
+	return n.session.Invalidate(reason)
+}
+
+// Reactivate makes the whole session active again
+func (n *Namespace) Reactivate() error {
+	return n.session.Reactivate()
+}
+
+// Metadata returns the metadata of the whole session
+func (n *Namespace) Metadata() Metadata {
+	return n.session.Metadata()
+}
+
+// SetClient records the client using the whole session
+func (n *Namespace) SetClient(client ClientInfo) error {
+	return n.session.SetClient(client)
+}
+
+// Keys returns the sorted keys of the namespace without its prefix
+func (n *Namespace) Keys() []string {
+	n.session.m.RLock()
+	defer n.session.m.RUnlock()
+	keys := []string{}
+	for key := range n.session.Data {
+		if strings.HasPrefix(key, n.prefix) {
+			keys = append(keys, strings.TrimPrefix(key, n.prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of keys of the namespace
+func (n *Namespace) Len() int {
+	return len(n.Keys())
+}
+
+// All returns a copy of the namespace data without the prefix of the keys
+func (n *Namespace) All() map[string]interface{} {
+	n.session.m.RLock()
+	defer n.session.m.RUnlock()
+	data := make(map[string]interface{})
+	for key, value := range n.session.Data {
+		if strings.HasPrefix(key, n.prefix) {
+			data[strings.TrimPrefix(key, n.prefix)] = value
+		}
+	}
+	return data
+}
+
+// SetMany sets several values to the namespace
+func (n *Namespace) SetMany(values map[string]interface{}) error {
+	scoped := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		scoped[n.prefix+key] = value
+	}
+	return n.session.SetMany(scoped)
+}
+
+// DeleteMany deletes several values from the namespace, the keys not found are ignored
+func (n *Namespace) DeleteMany(keys ...string) error {
+	scoped := make([]string, len(keys))
+	for i, key := range keys {
+		scoped[i] = n.prefix + key
+	}
+	return n.session.DeleteMany(scoped...)
+}
+
+// Update runs fn in a transaction of the session with the keys scoped to the namespace
+func (n *Namespace) Update(fn func(tx SessionTx) error) error {
+	return n.session.Update(func(tx SessionTx) error {
+		return fn(&namespaceTx{tx: tx, prefix: n.prefix})
+	})
+}
+
+// Clear deletes every key of the namespace
+//   - None of the keys is deleted if the session manager fails to record the deletions
+func (n *Namespace) Clear() error {
+	n.session.m.Lock()
+	defer n.session.m.Unlock()
+	var changes []sessionChange
+	for key := range n.session.Data {
+		if strings.HasPrefix(key, n.prefix) {
+			changes = append(changes, sessionChange{Kind: changeDelete, Key: key})
+		}
+	}
+	if err := n.session.notify(changes...); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		delete(n.session.Data, change.Key)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package sessionmanager_test
+
+import (
+	"testing"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_Namespace(t *testing.T) {
+	cases := map[string]struct {
+		action   func(s *sessionmanager.Session) error
+		cart     []string
+		expected map[string]interface{}
+	}{
+		"scoped keys": {
+			action: func(s *sessionmanager.Session) error {
+				return s.Namespace("cart").Set("id", 2)
+			},
+			cart:     []string{"id", "items:count"},
+			expected: map[string]interface{}{"id": 1, "auth:id": 3, "cart:id": 2, "cart:items:count": 4},
+		},
+
+		"delete scoped key": {
+			action: func(s *sessionmanager.Session) error {
+				return s.Namespace("cart").Delete("items:count")
+			},
+			cart:     []string{},
+			expected: map[string]interface{}{"id": 1, "auth:id": 3},
+		},
+
+		"clear namespace": {
+			action: func(s *sessionmanager.Session) error {
+				return s.Namespace("cart").Clear()
+			},
+			cart:     []string{},
+			expected: map[string]interface{}{"id": 1, "auth:id": 3},
+		},
+
+		"clear nested namespace": {
+			action: func(s *sessionmanager.Session) error {
+				return s.Namespace("cart").Namespace("items").Clear()
+			},
+			cart:     []string{},
+			expected: map[string]interface{}{"id": 1, "auth:id": 3},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := sessionmanager.NewSession(map[string]interface{}{"id": 1})
+			assert.NoError(t, s.Namespace("auth").Set("id", 3))
+			assert.NoError(t, s.Namespace("cart").Namespace("items").Set("count", 4))
+
+			assert.NoError(t, tc.action(s))
+			assert.Equal(t, tc.cart, s.Namespace("cart").Keys())
+			assert.Equal(t, tc.expected, s.Data)
+		})
+	}
+}
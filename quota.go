@@ -0,0 +1,161 @@
+package sessionmanager
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Quota limits the data stored in a session, a zero value means no limit
+type Quota struct {
+	// MaxKeys is the maximum number of keys of the session data
+	MaxKeys int
+	// MaxKeyLength is the maximum length in bytes of a key
+	MaxKeyLength int
+	// MaxValueSize is the maximum size in bytes of a value once encoded with gob,
+	// which is an estimation of the space it takes in a store
+	MaxValueSize int
+}
+
+// QuotaLimit identifies the limit of a quota that was exceeded
+type QuotaLimit string
+
+// Limits of a quota reported by QuotaError
+const (
+	QuotaKeys      QuotaLimit = "keys"
+	QuotaKeyLength QuotaLimit = "key_length"
+	QuotaValueSize QuotaLimit = "value_size"
+)
+
+// QuotaError is returned by Set when the value does not fit in the session quota
+type QuotaError struct {
+	SessionId string
+	Key       string
+	Limit     QuotaLimit
+	Max       int
+	Actual    int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Session ID %s quota exceeded setting key %s: %s is %d, maximum %d", e.SessionId, e.Key, e.Limit, e.Actual, e.Max)
+}
+
+// Footprint is the estimated size of the data of a session
+type Footprint struct {
+	Keys       int
+	KeyBytes   int
+	ValueBytes int
+	// Largest is the key with the largest encoded value
+	Largest string
+}
+
+// Bytes returns the estimated size of keys and values together
+func (f Footprint) Bytes() int {
+	return f.KeyBytes + f.ValueBytes
+}
+
+// SetQuota sets the limits enforced by Set, the data already stored is not checked
+func (s *Session) SetQuota(quota Quota) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.quota = quota
+}
+
+// Footprint returns the estimated size of the session data
+//   - Values are measured encoded with gob, so custom types must be registered with gob.Register
+func (s *Session) Footprint() (Footprint, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	keys := make([]string, 0, len(s.Data))
+	for key := range s.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var footprint Footprint
+	largest := -1
+	for _, key := range keys {
+		size, err := valueSize(s.Data[key])
+		if err != nil {
+			return Footprint{}, fmt.Errorf("measuring key %s: %w", key, err)
+		}
+		footprint.Keys++
+		footprint.KeyBytes += len(key)
+		footprint.ValueBytes += size
+		if size > largest {
+			footprint.Largest, largest = key, size
+		}
+	}
+	return footprint, nil
+}
+
+// checkQuota returns a *QuotaError if the key and the value do not fit in the quota
+// of a session with keys keys, the lock must be held
+func (s *Session) checkQuota(key string, value interface{}, keys int) error {
+	if s.quota.MaxKeys > 0 && keys >= s.quota.MaxKeys {
+		return &QuotaError{SessionId: s.ID, Key: key, Limit: QuotaKeys, Max: s.quota.MaxKeys, Actual: keys + 1}
+	}
+	if s.quota.MaxKeyLength > 0 && len(key) > s.quota.MaxKeyLength {
+		return &QuotaError{SessionId: s.ID, Key: key, Limit: QuotaKeyLength, Max: s.quota.MaxKeyLength, Actual: len(key)}
+	}
+	if s.quota.MaxValueSize > 0 {
+		size, err := valueSize(value)
+		if err != nil {
+			return fmt.Errorf("measuring key %s: %w", key, err)
+		}
+		if size > s.quota.MaxValueSize {
+			return &QuotaError{SessionId: s.ID, Key: key, Limit: QuotaValueSize, Max: s.quota.MaxValueSize, Actual: size}
+		}
+	}
+	return nil
+}
+
+// valueSize returns the size of a value encoded with gob
+func valueSize(value interface{}) (int, error) {
+	data, err := encodeValue(value)
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// SetQuota sets the quota of the sessions held by the session manager and of the new ones
+//   - Sessions of tenants with their own quota keep it
+func (sm *SessionManager) SetQuota(quota Quota) {
+	sm.quota.Store(&quota)
+	sm.m.RLock()
+	defer sm.m.RUnlock()
+	for sessionId, session := range sm.Sessions {
+		if s, ok := session.(*Session); ok {
+			s.SetQuota(*sm.quotaOf(sessionId))
+		}
+	}
+}
+
+// quotaOf returns the quota of a session, the quota of its tenant if it has one or the manager quota
+func (sm *SessionManager) quotaOf(sessionId string) *Quota {
+	if tenant := sm.tenantOf(sessionId); tenant != nil {
+		if quota := tenant.getQuota(); quota != nil {
+			return quota
+		}
+	}
+	return sm.quota.Load()
+}
+
+// Footprints returns the estimated size of the data of every session held in memory
+func (sm *SessionManager) Footprints() (map[string]Footprint, error) {
+	sm.m.RLock()
+	defer sm.m.RUnlock()
+	footprints := make(map[string]Footprint, len(sm.Sessions))
+	for sessionId, session := range sm.Sessions {
+		s, ok := session.(*Session)
+		if !ok {
+			continue
+		}
+		footprint, err := s.Footprint()
+		if err != nil {
+			return nil, fmt.Errorf("Session ID %s: %w", sessionId, err)
+		}
+		footprints[sessionId] = footprint
+	}
+	return footprints, nil
+}
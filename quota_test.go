@@ -0,0 +1,84 @@
+package sessionmanager_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_SetQuota(t *testing.T) {
+	cases := map[string]struct {
+		quota sessionmanager.Quota
+		key   string
+		value interface{}
+		limit sessionmanager.QuotaLimit
+	}{
+		"within quota": {
+			quota: sessionmanager.Quota{MaxKeys: 2, MaxKeyLength: 8, MaxValueSize: 64},
+			key:   "user",
+			value: "solrac",
+		},
+
+		"too many keys": {
+			quota: sessionmanager.Quota{MaxKeys: 1},
+			key:   "user",
+			value: "solrac",
+			limit: sessionmanager.QuotaKeys,
+		},
+
+		"key too long": {
+			quota: sessionmanager.Quota{MaxKeyLength: 3},
+			key:   "user",
+			value: "solrac",
+			limit: sessionmanager.QuotaKeyLength,
+		},
+
+		"value too large": {
+			quota: sessionmanager.Quota{MaxValueSize: 64},
+			key:   "user",
+			value: strings.Repeat("x", 128),
+			limit: sessionmanager.QuotaValueSize,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetQuota(tc.quota)
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, s.Set("id", 1))
+
+			err = s.Set(tc.key, tc.value)
+			if tc.limit == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var quota *sessionmanager.QuotaError
+			assert.True(t, errors.As(err, &quota))
+			assert.Equal(t, tc.limit, quota.Limit)
+			assert.Greater(t, quota.Actual, quota.Max)
+			_, err = s.Get(tc.key)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSessionManager_Footprints(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	s, err := sm.CreateSession()
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set("user", "solrac"))
+	assert.NoError(t, s.Set("cart", strings.Repeat("x", 1024)))
+
+	footprints, err := sm.Footprints()
+	assert.NoError(t, err)
+	footprint := footprints[s.SessionId()]
+	assert.Equal(t, 2, footprint.Keys)
+	assert.Equal(t, 8, footprint.KeyBytes)
+	assert.Greater(t, footprint.ValueBytes, 1024)
+	assert.Equal(t, "cart", footprint.Largest)
+}
@@ -0,0 +1,481 @@
+package sessionmanager
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields of the redis hash that are not session data
+const (
+	redisFieldExpirationTime   = "expiration_time"
+	redisFieldActive           = "active"
+	redisFieldExpired          = "expired"
+	redisFieldVersion          = "version"
+	redisFieldRevokedReason    = "revoked_reason"
+	redisFieldRevokedAt        = "revoked_at"
+	redisFieldCreatedAt        = "created_at"
+	redisFieldLastAccessedAt   = "last_accessed_at"
+	redisFieldAccessCount      = "access_count"
+	redisFieldClientIP         = "client_ip"
+	redisFieldClientAgent      = "client_user_agent"
+	redisFieldClientDevice     = "client_device"
+	redisFieldFingerprintIP    = "fingerprint_ip"
+	redisFieldFingerprintAgent = "fingerprint_user_agent"
+	redisFieldFingerprintCert  = "fingerprint_certificate"
+	redisDataPrefix            = "data:"
+)
+
+// RedisStoreOptions configures the connection of a RedisStore
+type RedisStoreOptions struct {
+	// Addr is the host:port of the redis server
+	Addr string
+	// Password used to authenticate if it is not empty
+	Password string
+	// DB is the database selected after connecting
+	DB int
+	// Prefix is prepended to the session ids to build the keys, by default "session:"
+	Prefix string
+	// DialTimeout is the timeout to connect to the server, by default 5 seconds
+	DialTimeout time.Duration
+	// MaxIdleConns is the number of connections kept open between commands, by default 4
+	MaxIdleConns int
+}
+
+// RedisStore is a store that keeps each session as a redis hash speaking the RESP protocol
+//   - The data keys are stored as "data:<key>" fields with the values encoded with encoding/gob
+//   - The key expires at the ExpirationTime of the session, so redis removes the expired sessions
+//   - Saves WATCH the key, so a session saved by another process in between is reported as ErrVersionConflict
+type RedisStore struct {
+	opts RedisStoreOptions
+	m    sync.Mutex
+	idle []*redisConn
+}
+
+// Verify that RedisStore implements Store
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore is the constructor for redis store, connections are opened when needed
+func NewRedisStore(opts RedisStoreOptions) *RedisStore {
+	if opts.Prefix == "" {
+		opts.Prefix = "session:"
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 4
+	}
+	return &RedisStore{opts: opts}
+}
+
+// Load a session from its redis hash
+func (rs *RedisStore) Load(ctx context.Context, sessionId string) (*Session, error) {
+	replies, err := rs.do(ctx, []string{"HGETALL", rs.key(sessionId)})
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := replies[0].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected HGETALL reply %T", replies[0])
+	}
+	if len(fields) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	record := SessionRecord{ID: sessionId, Data: make(map[string]interface{})}
+	for i := 0; i+1 < len(fields); i += 2 {
+		field, _ := fields[i].([]byte)
+		value, _ := fields[i+1].([]byte)
+		switch name := string(field); {
+		case name == redisFieldExpirationTime:
+			nanos, err := strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("redis: invalid expiration time: %w", err)
+			}
+			record.ExpirationTime = time.Unix(0, nanos)
+		case name == redisFieldActive:
+			record.Active = string(value) == "1"
+		case name == redisFieldExpired:
+			record.Expired = string(value) == "1"
+		case name == redisFieldVersion:
+			version, err := strconv.ParseUint(string(value), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("redis: invalid version: %w", err)
+			}
+			record.Version = version
+		case name == redisFieldRevokedReason:
+			record.RevokedReason = RevocationReason(value)
+		case name == redisFieldRevokedAt:
+			if record.RevokedAt, err = redisTime(value); err != nil {
+				return nil, fmt.Errorf("redis: invalid revocation time: %w", err)
+			}
+		case name == redisFieldCreatedAt:
+			if record.CreatedAt, err = redisTime(value); err != nil {
+				return nil, fmt.Errorf("redis: invalid creation time: %w", err)
+			}
+		case name == redisFieldLastAccessedAt:
+			if record.LastAccessedAt, err = redisTime(value); err != nil {
+				return nil, fmt.Errorf("redis: invalid last access time: %w", err)
+			}
+		case name == redisFieldAccessCount:
+			if record.AccessCount, err = strconv.ParseUint(string(value), 10, 64); err != nil {
+				return nil, fmt.Errorf("redis: invalid access count: %w", err)
+			}
+		case name == redisFieldClientIP:
+			record.Client.IP = string(value)
+		case name == redisFieldClientAgent:
+			record.Client.UserAgent = string(value)
+		case name == redisFieldClientDevice:
+			record.Client.Device = string(value)
+		case name == redisFieldFingerprintIP:
+			record.Fingerprint.IP = string(value)
+		case name == redisFieldFingerprintAgent:
+			record.Fingerprint.UserAgent = string(value)
+		case name == redisFieldFingerprintCert:
+			record.Fingerprint.Certificate = string(value)
+		case strings.HasPrefix(name, redisDataPrefix):
+			v, err := decodeValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("redis: decoding key %s: %w", strings.TrimPrefix(name, redisDataPrefix), err)
+			}
+			record.Data[strings.TrimPrefix(name, redisDataPrefix)] = v
+		}
+	}
+	return record.Session(), nil
+}
+
+// Save replaces the redis hash of the session and sets its expiration
+//   - Once stored only the data fields changed since the session was loaded are written
+func (rs *RedisStore) Save(ctx context.Context, s *Session) error {
+	s.m.RLock()
+	record := s.record()
+	changes := s.changes()
+	s.m.RUnlock()
+	key := rs.key(record.ID)
+
+	if record.IsExpired() {
+		_, err := rs.do(ctx, []string{"DEL", key})
+		return err
+	}
+
+	data := record.Data
+	var commands [][]string
+	if record.Version > 0 {
+		data = changes.Set
+		if len(changes.Deleted) > 0 {
+			hdel := []string{"HDEL", key}
+			for _, k := range changes.Deleted {
+				hdel = append(hdel, redisDataPrefix+k)
+			}
+			commands = append(commands, hdel)
+		}
+	} else {
+		commands = append(commands, []string{"DEL", key})
+	}
+
+	hset := []string{
+		"HSET", key,
+		redisFieldExpirationTime, strconv.FormatInt(record.ExpirationTime.UnixNano(), 10),
+		redisFieldActive, redisBool(record.Active),
+		redisFieldExpired, redisBool(record.Expired),
+		redisFieldVersion, strconv.FormatUint(record.Version+1, 10),
+		redisFieldRevokedReason, string(record.RevokedReason),
+		redisFieldRevokedAt, strconv.FormatInt(unixNanoOrZero(record.RevokedAt), 10),
+		redisFieldCreatedAt, strconv.FormatInt(unixNanoOrZero(record.CreatedAt), 10),
+		redisFieldLastAccessedAt, strconv.FormatInt(unixNanoOrZero(record.LastAccessedAt), 10),
+		redisFieldAccessCount, strconv.FormatUint(record.AccessCount, 10),
+		redisFieldClientIP, record.Client.IP,
+		redisFieldClientAgent, record.Client.UserAgent,
+		redisFieldClientDevice, record.Client.Device,
+		redisFieldFingerprintIP, record.Fingerprint.IP,
+		redisFieldFingerprintAgent, record.Fingerprint.UserAgent,
+		redisFieldFingerprintCert, record.Fingerprint.Certificate,
+	}
+	for k, v := range data {
+		encoded, err := encodeValue(v)
+		if err != nil {
+			return fmt.Errorf("redis: encoding key %s: %w", k, err)
+		}
+		hset = append(hset, redisDataPrefix+k, string(encoded))
+	}
+	commands = append([][]string{{"MULTI"}}, commands...)
+	commands = append(commands,
+		hset,
+		[]string{"PEXPIREAT", key, strconv.FormatInt(record.ExpirationTime.UnixMilli(), 10)},
+		[]string{"EXEC"},
+	)
+
+	err := rs.withConn(ctx, func(conn *redisConn) error {
+		replies, err := conn.pipeline(ctx, []string{"WATCH", key}, []string{"HGET", key, redisFieldVersion})
+		if err != nil {
+			return err
+		}
+		var stored uint64
+		if value, ok := replies[1].([]byte); ok {
+			if stored, err = strconv.ParseUint(string(value), 10, 64); err != nil {
+				return fmt.Errorf("redis: invalid version: %w", err)
+			}
+		}
+		if stored != record.Version {
+			if _, err := conn.pipeline(ctx, []string{"UNWATCH"}); err != nil {
+				return err
+			}
+			return VersionConflict(record.ID, record.Version)
+		}
+
+		replies, err = conn.pipeline(ctx, commands...)
+		if err != nil {
+			return err
+		}
+		// EXEC replies nil when the watched key changed before it
+		results, ok := replies[len(replies)-1].([]interface{})
+		if !ok {
+			return VersionConflict(record.ID, record.Version)
+		}
+		for _, result := range results {
+			if err, ok := result.(redisError); ok {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.Saved(record.Version + 1)
+	return nil
+}
+
+// Delete removes the redis hash of the session
+func (rs *RedisStore) Delete(ctx context.Context, sessionId string) error {
+	replies, err := rs.do(ctx, []string{"DEL", rs.key(sessionId)})
+	if err != nil {
+		return err
+	}
+	if deleted, _ := replies[0].(int64); deleted == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Close closes the idle connections of the store
+func (rs *RedisStore) Close() error {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	var err error
+	for _, conn := range rs.idle {
+		if closeErr := conn.conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	rs.idle = nil
+	return err
+}
+
+// key returns the redis key of a session
+func (rs *RedisStore) key(sessionId string) string {
+	return rs.opts.Prefix + sessionId
+}
+
+// do sends the commands in a pipeline and returns their replies, a
+// redis error reply is returned as error unless it is inside a transaction
+func (rs *RedisStore) do(ctx context.Context, commands ...[]string) ([]interface{}, error) {
+	var replies []interface{}
+	err := rs.withConn(ctx, func(conn *redisConn) (err error) {
+		replies, err = conn.pipeline(ctx, commands...)
+		return err
+	})
+	return replies, err
+}
+
+// withConn runs fn with a connection of the pool, the connection is closed
+// instead of reused if fn fails with an error that can leave it out of sync
+func (rs *RedisStore) withConn(ctx context.Context, fn func(conn *redisConn) error) error {
+	conn, err := rs.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(conn); err != nil {
+		var replyErr redisError
+		if errors.As(err, &replyErr) || errors.Is(err, ErrVersionConflict) {
+			rs.put(conn)
+		} else {
+			conn.conn.Close()
+		}
+		return err
+	}
+	rs.put(conn)
+	return nil
+}
+
+// get returns an idle connection or opens a new one
+func (rs *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	rs.m.Lock()
+	if n := len(rs.idle); n > 0 {
+		conn := rs.idle[n-1]
+		rs.idle = rs.idle[:n-1]
+		rs.m.Unlock()
+		return conn, nil
+	}
+	rs.m.Unlock()
+
+	dialer := net.Dialer{Timeout: rs.opts.DialTimeout}
+	c, err := dialer.DialContext(ctx, "tcp", rs.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+
+	var setup [][]string
+	if rs.opts.Password != "" {
+		setup = append(setup, []string{"AUTH", rs.opts.Password})
+	}
+	if rs.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(rs.opts.DB)})
+	}
+	if len(setup) > 0 {
+		if _, err := conn.pipeline(ctx, setup...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// put returns a connection to the idle pool
+func (rs *RedisStore) put(conn *redisConn) {
+	rs.m.Lock()
+	defer rs.m.Unlock()
+	if len(rs.idle) >= rs.opts.MaxIdleConns {
+		conn.conn.Close()
+		return
+	}
+	rs.idle = append(rs.idle, conn)
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn is a connection speaking the RESP protocol
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// pipeline writes the commands and reads a reply for each of them
+func (c *redisConn) pipeline(ctx context.Context, commands ...[]string) ([]interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	for _, args := range commands {
+		fmt.Fprintf(c.w, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	var replyErr error
+	for i := range commands {
+		reply, err := readRESP(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if err, ok := reply.(redisError); ok && replyErr == nil {
+			replyErr = err
+		}
+		replies[i] = reply
+	}
+	if replyErr != nil {
+		return nil, replyErr
+	}
+	return replies, nil
+}
+
+// readRESP reads a reply, errors inside arrays are returned as redisError values
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return redisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+// unixNanoOrZero returns the unix nanoseconds of t or 0 if t is the zero time
+func unixNanoOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// redisTime decodes the unix nanoseconds of a hash field, 0 is the zero time
+func redisTime(value []byte) (time.Time, error) {
+	nanos, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil || nanos == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// redisBool encodes a bool as a hash field value
+func redisBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
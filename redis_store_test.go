@@ -0,0 +1,390 @@
+package sessionmanager_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+// respServer is an in-process stand-in of a redis server supporting the commands used by RedisStore
+type respServer struct {
+	listener net.Listener
+	password string
+	m        sync.Mutex
+	hashes   map[string]map[string]string
+	expires  map[string]time.Time
+	// changes counts the writes to each key, so WATCH can detect them
+	changes map[string]int
+}
+
+func newRESPServer(t *testing.T, password string) *respServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &respServer{
+		listener: listener,
+		password: password,
+		hashes:   map[string]map[string]string{},
+		expires:  map[string]time.Time{},
+		changes:  map[string]int{},
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (s *respServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *respServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *respServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	var queue [][]string
+	inMulti := false
+	watched := map[string]int{}
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+
+		switch {
+		case name == "AUTH":
+			authenticated = len(args) == 2 && args[1] == s.password
+			if !authenticated {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			io.WriteString(conn, "+OK\r\n")
+		case !authenticated:
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+		case name == "MULTI":
+			inMulti = true
+			queue = nil
+			io.WriteString(conn, "+OK\r\n")
+		case name == "WATCH":
+			for _, key := range args[1:] {
+				watched[key] = s.changed(key)
+			}
+			io.WriteString(conn, "+OK\r\n")
+		case name == "UNWATCH":
+			watched = map[string]int{}
+			io.WriteString(conn, "+OK\r\n")
+		case name == "EXEC":
+			inMulti = false
+			aborted := false
+			for key, changes := range watched {
+				aborted = aborted || s.changed(key) != changes
+			}
+			watched = map[string]int{}
+			if aborted {
+				io.WriteString(conn, "*-1\r\n")
+				continue
+			}
+			replies := make([]string, len(queue))
+			for i, queued := range queue {
+				replies[i] = s.exec(queued)
+			}
+			fmt.Fprintf(conn, "*%d\r\n%s", len(replies), strings.Join(replies, ""))
+		case inMulti:
+			queue = append(queue, args)
+			io.WriteString(conn, "+QUEUED\r\n")
+		default:
+			io.WriteString(conn, s.exec(args))
+		}
+	}
+}
+
+func (s *respServer) exec(args []string) string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+		if expire, ok := s.expires[key]; ok && !time.Now().Before(expire) {
+			delete(s.hashes, key)
+			delete(s.expires, key)
+		}
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "HSET":
+		if s.hashes[key] == nil {
+			s.hashes[key] = map[string]string{}
+		}
+		added := 0
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := s.hashes[key][args[i]]; !ok {
+				added++
+			}
+			s.hashes[key][args[i]] = args[i+1]
+		}
+		s.changes[key]++
+		return fmt.Sprintf(":%d\r\n", added)
+	case "HGET":
+		value, ok := s.hashes[key][args[2]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "HGETALL":
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(s.hashes[key])*2)
+		for field, value := range s.hashes[key] {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n$%d\r\n%s\r\n", len(field), field, len(value), value)
+		}
+		return b.String()
+	case "HDEL":
+		deleted := 0
+		for _, field := range args[2:] {
+			if _, ok := s.hashes[key][field]; ok {
+				delete(s.hashes[key], field)
+				deleted++
+			}
+		}
+		s.changes[key]++
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "DEL":
+		_, ok := s.hashes[key]
+		delete(s.hashes, key)
+		delete(s.expires, key)
+		if ok {
+			s.changes[key]++
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "PEXPIREAT":
+		ms, _ := strconv.ParseInt(args[2], 10, 64)
+		if _, ok := s.hashes[key]; !ok {
+			return ":0\r\n"
+		}
+		s.expires[key] = time.UnixMilli(ms)
+		return ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func (s *respServer) changed(key string) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.changes[key]
+}
+
+func (s *respServer) expiration(key string) time.Time {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.expires[key]
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisStore_SaveAndLoad(t *testing.T) {
+	cases := map[string]struct {
+		password   string
+		data       map[string]interface{}
+		expiration time.Duration
+		err        error
+	}{
+		"empty": {
+			data:       map[string]interface{}{},
+			expiration: time.Hour,
+		},
+
+		"with data": {
+			data: map[string]interface{}{
+				"user":  "solrac",
+				"age":   20,
+				"roles": []string{"admin", "user"},
+			},
+			expiration: time.Hour,
+		},
+
+		"with password": {
+			password:   "secret",
+			data:       map[string]interface{}{"user": "solrac"},
+			expiration: time.Hour,
+		},
+
+		"expired": {
+			data:       map[string]interface{}{"user": "solrac"},
+			expiration: -time.Minute,
+			err:        sessionmanager.ErrSessionNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := newRESPServer(t, tc.password)
+			store := sessionmanager.NewRedisStore(sessionmanager.RedisStoreOptions{Addr: server.addr(), Password: tc.password})
+			defer store.Close()
+
+			session := sessionmanager.NewSession(tc.data)
+			expiration := time.Now().Add(tc.expiration)
+			session.SetExpirationTime(expiration)
+
+			err := store.Save(context.Background(), session)
+			assert.NoError(t, err)
+
+			loaded, err := store.Load(context.Background(), session.SessionId())
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "expected %s, actual %v", tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, session.ID, loaded.ID)
+			assert.Equal(t, tc.data, loaded.Data)
+			assert.True(t, expiration.Equal(loaded.ExpirationTime))
+			assert.True(t, loaded.IsActive())
+			assert.Equal(t, expiration.UnixMilli(), server.expiration("session:"+session.ID).UnixMilli())
+		})
+	}
+}
+
+func TestRedisStore_Delete(t *testing.T) {
+	cases := map[string]struct {
+		saved bool
+		err   error
+	}{
+		"saved": {
+			saved: true,
+		},
+
+		"not found": {
+			err: sessionmanager.ErrSessionNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := newRESPServer(t, "")
+			store := sessionmanager.NewRedisStore(sessionmanager.RedisStoreOptions{Addr: server.addr()})
+			defer store.Close()
+
+			session := sessionmanager.NewSession(nil)
+			if tc.saved {
+				assert.NoError(t, store.Save(context.Background(), session))
+			}
+
+			err := store.Delete(context.Background(), session.SessionId())
+			assert.Equal(t, tc.err, err)
+
+			_, err = store.Load(context.Background(), session.SessionId())
+			assert.Equal(t, sessionmanager.ErrSessionNotFound, err)
+		})
+	}
+}
+
+func TestSessionManager_SetStore(t *testing.T) {
+	cases := map[string]struct {
+		action func(t *testing.T, first, second *sessionmanager.SessionManager, s sessionmanager.ISession)
+	}{
+		"shared between managers": {
+			action: func(t *testing.T, first, second *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				s.Set("user", "solrac")
+				assert.NoError(t, first.SaveSession(s))
+
+				loaded, err := second.GetSession(s.SessionId())
+				assert.NoError(t, err)
+				value, _ := loaded.Get("user")
+				assert.Equal(t, "solrac", value)
+			},
+		},
+
+		"changes are seen after saving": {
+			action: func(t *testing.T, first, second *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				loaded, _ := second.GetSession(s.SessionId())
+				loaded.Set("cart", 3)
+				assert.NoError(t, second.SaveSession(loaded))
+
+				reloaded, err := first.GetSession(s.SessionId())
+				assert.NoError(t, err)
+				value, _ := reloaded.Get("cart")
+				assert.Equal(t, 3, value)
+			},
+		},
+
+		"destroyed in other manager": {
+			action: func(t *testing.T, first, second *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				assert.NoError(t, second.DestroySession(s.SessionId()))
+
+				_, err := first.GetSession(s.SessionId())
+				assert.Error(t, err)
+				assert.Empty(t, first.GetAllSessions())
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := newRESPServer(t, "")
+			store := sessionmanager.NewRedisStore(sessionmanager.RedisStoreOptions{Addr: server.addr()})
+			defer store.Close()
+
+			first := sessionmanager.NewSessionManager()
+			first.SetStore(store)
+			second := sessionmanager.NewSessionManager()
+			second.SetStore(store)
+
+			s, err := first.CreateSession()
+			assert.NoError(t, err)
+			tc.action(t, first, second, s)
+		})
+	}
+}
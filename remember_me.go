@@ -0,0 +1,366 @@
+package sessionmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RememberSubjectKey is the session data key holding the subject of the sessions created by RememberMe.Login
+const RememberSubjectKey = "remember.subject"
+
+// ErrRememberToken is wrapped by the errors returned for unknown, expired or malformed remember-me tokens
+var ErrRememberToken = errors.New("invalid remember-me token")
+
+// ErrRememberTokenReused is returned when a remember-me token that was already
+// rotated is presented again, which means it was stolen, so its series is revoked
+var ErrRememberTokenReused = errors.New("remember-me token reused")
+
+// RememberToken is a stored remember-me token
+//   - Only the SHA-256 hash of the validator is stored, so the tokens can not be
+//     used by someone reading the store
+type RememberToken struct {
+	Selector      string
+	ValidatorHash string
+	// Series is shared by the tokens rotated from the same login
+	Series  string
+	Subject string
+	// SessionId is the session created with the token, revoked with the series
+	SessionId string
+	ExpiresAt time.Time
+	// Used is true once the token was rotated
+	Used bool
+}
+
+// RememberStore persists the remember-me tokens
+type RememberStore interface {
+	// Save stores a new token
+	Save(ctx context.Context, token RememberToken) error
+	// Load a token by selector, returns ErrRememberToken if it does not exist
+	Load(ctx context.Context, selector string) (RememberToken, error)
+	// MarkUsed marks a token as used, returns false if it already was
+	MarkUsed(ctx context.Context, selector string) (bool, error)
+	// Series returns the tokens of a series
+	Series(ctx context.Context, series string) ([]RememberToken, error)
+	// DeleteSeries deletes the tokens of a series
+	DeleteSeries(ctx context.Context, series string) error
+}
+
+// RememberMeOptions configures the remember-me tokens and their cookie
+type RememberMeOptions struct {
+	// Store keeps the tokens, by default a MemoryRememberStore
+	Store RememberStore
+	// TTL is how long a token can be used, by default 30 days, each rotation extends it
+	TTL time.Duration
+	// CookieName is the cookie holding the token, by default "remember_me"
+	CookieName string
+	// CookiePath is the path of the cookie, by default "/"
+	CookiePath string
+	// CookieDomain is the domain of the cookie, by default the host of the request
+	CookieDomain string
+	// Secure sends the cookie only over https
+	Secure bool
+}
+
+// RememberMe issues persistent login tokens made of a selector, that finds the
+// stored token, and a validator, that proves the client holds it
+type RememberMe struct {
+	sm   *SessionManager
+	opts RememberMeOptions
+}
+
+// NewRememberMe is the constructor for the remember-me tokens of a session manager
+func NewRememberMe(sm *SessionManager, opts RememberMeOptions) *RememberMe {
+	if opts.Store == nil {
+		opts.Store = NewMemoryRememberStore()
+	}
+	if opts.TTL == 0 {
+		opts.TTL = 30 * 24 * time.Hour
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = "remember_me"
+	}
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+	return &RememberMe{sm: sm, opts: opts}
+}
+
+// Issue starts a new series of tokens for subject after it logged in to the
+// session s and returns the token to send to the client
+func (rm *RememberMe) Issue(ctx context.Context, subject string, s ISession) (string, error) {
+	series, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return rm.issue(ctx, RememberToken{Series: series, Subject: subject, SessionId: s.SessionId()})
+}
+
+// Login creates a new session for the subject of the token with CreateSession,
+// saves it and returns it with the rotated token that replaces the presented one
+//   - A token presented again after being rotated revokes every session and token
+//     of its series and returns ErrRememberTokenReused
+//   - The presented token is only marked as used once the new session and token are
+//     stored, if the login fails before that the new session is destroyed and the
+//     presented token can be used again
+func (rm *RememberMe) Login(ctx context.Context, token string) (ISession, string, error) {
+	stored, err := rm.verify(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+	if stored.Used {
+		return nil, "", rm.reused(ctx, stored)
+	}
+
+	session, err := rm.sm.CreateSessionContext(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := session.Set(RememberSubjectKey, stored.Subject); err != nil {
+		return nil, "", rm.discard(ctx, session, err)
+	}
+	if err := rm.sm.SaveSessionContext(ctx, session); err != nil {
+		return nil, "", rm.discard(ctx, session, err)
+	}
+	rotated, err := rm.issue(ctx, RememberToken{Series: stored.Series, Subject: stored.Subject, SessionId: session.SessionId()})
+	if err != nil {
+		return nil, "", rm.discard(ctx, session, err)
+	}
+	first, err := rm.opts.Store.MarkUsed(ctx, stored.Selector)
+	if err != nil {
+		return nil, "", rm.discard(ctx, session, err)
+	}
+	if !first {
+		// Another login rotated the token first
+		rm.discard(ctx, session, nil)
+		return nil, "", rm.reused(ctx, stored)
+	}
+	rm.sm.log(slog.LevelInfo, "session created from remember-me token", session.SessionId())
+	return session, rotated, nil
+}
+
+// Forget deletes the series of the token, call it when the subject logs out
+func (rm *RememberMe) Forget(ctx context.Context, token string) error {
+	stored, err := rm.verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	return rm.opts.Store.DeleteSeries(ctx, stored.Series)
+}
+
+// Cookie returns the cookie holding the token, an empty token returns a cookie that deletes it
+func (rm *RememberMe) Cookie(token string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     rm.opts.CookieName,
+		Value:    token,
+		Path:     rm.opts.CookiePath,
+		Domain:   rm.opts.CookieDomain,
+		Secure:   rm.opts.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if token == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = time.Now().Add(rm.opts.TTL)
+	}
+	return cookie
+}
+
+// requestLogin logs in the client with the remember-me cookie of the request,
+// it returns nil if the request has no valid token
+func (rm *RememberMe) requestLogin(ctx context.Context, w http.ResponseWriter, r *http.Request) ISession {
+	cookie, err := r.Cookie(rm.opts.CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	session, token, err := rm.Login(ctx, cookie.Value)
+	if err != nil {
+		rm.sm.log(slog.LevelInfo, "remember-me login failed", "", slog.String("error", err.Error()))
+		http.SetCookie(w, rm.Cookie(""))
+		return nil
+	}
+	http.SetCookie(w, rm.Cookie(token))
+	return session
+}
+
+// issue stores a new token of the series and returns it
+func (rm *RememberMe) issue(ctx context.Context, token RememberToken) (string, error) {
+	selector, err := randomToken(12)
+	if err != nil {
+		return "", err
+	}
+	validator, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	token.Selector = selector
+	token.ValidatorHash = hashValidator(validator)
+	token.ExpiresAt = time.Now().Add(rm.opts.TTL)
+	if err := rm.opts.Store.Save(ctx, token); err != nil {
+		return "", err
+	}
+	return selector + "." + validator, nil
+}
+
+// verify returns the stored token if the presented token matches it and did not expire
+func (rm *RememberMe) verify(ctx context.Context, token string) (RememberToken, error) {
+	selector, validator, ok := strings.Cut(token, ".")
+	if !ok || selector == "" || validator == "" {
+		return RememberToken{}, fmt.Errorf("%w: malformed token", ErrRememberToken)
+	}
+	stored, err := rm.opts.Store.Load(ctx, selector)
+	if err != nil {
+		return RememberToken{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashValidator(validator)), []byte(stored.ValidatorHash)) != 1 {
+		return RememberToken{}, fmt.Errorf("%w: validator does not match", ErrRememberToken)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return RememberToken{}, fmt.Errorf("%w: token expired", ErrRememberToken)
+	}
+	return stored, nil
+}
+
+// reused revokes the sessions and deletes the tokens of the series of a reused token
+func (rm *RememberMe) reused(ctx context.Context, token RememberToken) error {
+	rm.sm.log(slog.LevelWarn, "remember-me token reused, revoking its series", token.SessionId)
+	tokens, err := rm.opts.Store.Series(ctx, token.Series)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		session, err := rm.sm.GetSessionContext(ctx, t.SessionId)
+		if err != nil {
+			continue
+		}
+		if err := session.Invalidate(RevokedTokenTheft); err != nil {
+			return err
+		}
+		if err := rm.sm.SaveSessionContext(ctx, session); err != nil {
+			return err
+		}
+	}
+	if err := rm.opts.Store.DeleteSeries(ctx, token.Series); err != nil {
+		return err
+	}
+	return ErrRememberTokenReused
+}
+
+// discard destroys the session of a failed login and returns the error of the login
+func (rm *RememberMe) discard(ctx context.Context, session ISession, err error) error {
+	if destroyErr := rm.sm.DestroySessionContext(ctx, session.SessionId()); destroyErr != nil && !errors.Is(destroyErr, ErrSessionNotFound) {
+		rm.sm.log(slog.LevelError, "session of failed remember-me login not destroyed", session.SessionId(), slog.String("error", destroyErr.Error()))
+	}
+	return err
+}
+
+// randomToken returns size random bytes encoded with base64 for urls
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashValidator returns the hex encoded SHA-256 hash of a validator
+func hashValidator(validator string) string {
+	sum := sha256.Sum256([]byte(validator))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryRememberStore keeps the remember-me tokens in memory, the tokens are
+// lost when the process exits, so use it for tests or single process apps
+type MemoryRememberStore struct {
+	m      sync.Mutex
+	tokens map[string]RememberToken
+}
+
+// Verify that MemoryRememberStore implements RememberStore
+var _ RememberStore = (*MemoryRememberStore)(nil)
+
+// NewMemoryRememberStore is the constructor for the in-memory remember-me token store
+func NewMemoryRememberStore() *MemoryRememberStore {
+	return &MemoryRememberStore{tokens: make(map[string]RememberToken)}
+}
+
+func (ms *MemoryRememberStore) Save(ctx context.Context, token RememberToken) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	ms.tokens[token.Selector] = token
+	return nil
+}
+
+func (ms *MemoryRememberStore) Load(ctx context.Context, selector string) (RememberToken, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	token, ok := ms.tokens[selector]
+	if !ok {
+		return RememberToken{}, fmt.Errorf("%w: token not found", ErrRememberToken)
+	}
+	return token, nil
+}
+
+func (ms *MemoryRememberStore) MarkUsed(ctx context.Context, selector string) (bool, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	token, ok := ms.tokens[selector]
+	if !ok {
+		return false, fmt.Errorf("%w: token not found", ErrRememberToken)
+	}
+	if token.Used {
+		return false, nil
+	}
+	token.Used = true
+	ms.tokens[selector] = token
+	return true, nil
+}
+
+func (ms *MemoryRememberStore) Series(ctx context.Context, series string) ([]RememberToken, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	var tokens []RememberToken
+	for _, token := range ms.tokens {
+		if token.Series == series {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (ms *MemoryRememberStore) DeleteSeries(ctx context.Context, series string) error {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	for selector, token := range ms.tokens {
+		if token.Series == series {
+			delete(ms.tokens, selector)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired deletes the expired tokens, returns the number of deleted tokens
+func (ms *MemoryRememberStore) DeleteExpired(ctx context.Context) (int, error) {
+	ms.m.Lock()
+	defer ms.m.Unlock()
+	now := time.Now()
+	deleted := 0
+	for selector, token := range ms.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(ms.tokens, selector)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
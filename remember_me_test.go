@@ -0,0 +1,183 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberMe_Login(t *testing.T) {
+	ctx := context.Background()
+	sm := sessionmanager.NewSessionManager()
+	rm := sessionmanager.NewRememberMe(sm, sessionmanager.RememberMeOptions{})
+	login, err := sm.CreateSession()
+	assert.NoError(t, err)
+
+	token, err := rm.Issue(ctx, "user-1", login)
+	assert.NoError(t, err)
+
+	s, rotated, err := rm.Login(ctx, token)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, rotated)
+	subject, err := s.Get(sessionmanager.RememberSubjectKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+
+	next, rotated, err := rm.Login(ctx, rotated)
+	assert.NoError(t, err)
+
+	// The first token was stolen, presenting it again revokes the whole series
+	_, _, err = rm.Login(ctx, token)
+	assert.True(t, errors.Is(err, sessionmanager.ErrRememberTokenReused))
+	for _, session := range []sessionmanager.ISession{login, s, next} {
+		_, err := sm.GetSession(session.SessionId())
+		var revoked *sessionmanager.RevokedError
+		assert.True(t, errors.As(err, &revoked))
+		assert.Equal(t, sessionmanager.RevokedTokenTheft, revoked.Reason)
+	}
+	_, _, err = rm.Login(ctx, rotated)
+	assert.True(t, errors.Is(err, sessionmanager.ErrRememberToken))
+}
+
+// failingRememberStore fails to save tokens while fail is set
+type failingRememberStore struct {
+	*sessionmanager.MemoryRememberStore
+	fail bool
+}
+
+func (s *failingRememberStore) Save(ctx context.Context, token sessionmanager.RememberToken) error {
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	return s.MemoryRememberStore.Save(ctx, token)
+}
+
+func TestRememberMe_Login_Store(t *testing.T) {
+	ctx := context.Background()
+	boltStore := newBoltStore(t)
+	sm := sessionmanager.NewSessionManager()
+	sm.SetStore(boltStore)
+	tokens := &failingRememberStore{MemoryRememberStore: sessionmanager.NewMemoryRememberStore()}
+	rm := sessionmanager.NewRememberMe(sm, sessionmanager.RememberMeOptions{Store: tokens})
+	login, _ := sm.CreateSession()
+	token, err := rm.Issue(ctx, "user-1", login)
+	assert.NoError(t, err)
+
+	// A failed login destroys its session and does not burn the token
+	tokens.fail = true
+	_, _, err = rm.Login(ctx, token)
+	assert.Error(t, err)
+	assert.Len(t, sm.GetAllSessions(), 1)
+	tokens.fail = false
+
+	s, _, err := rm.Login(ctx, token)
+	assert.NoError(t, err)
+	stored, err := boltStore.Load(ctx, s.SessionId())
+	assert.NoError(t, err)
+	subject, err := stored.Get(sessionmanager.RememberSubjectKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+}
+
+func TestRememberMe_Login_Invalid(t *testing.T) {
+	cases := map[string]struct {
+		ttl   time.Duration
+		token func(token string) string
+	}{
+		"malformed": {
+			token: func(token string) string { return "malformed" },
+		},
+
+		"unknown selector": {
+			token: func(token string) string { return "unknown." + strings.Split(token, ".")[1] },
+		},
+
+		"wrong validator": {
+			token: func(token string) string { return strings.Split(token, ".")[0] + ".wrong" },
+		},
+
+		"expired": {
+			ttl:   -time.Minute,
+			token: func(token string) string { return token },
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			sm := sessionmanager.NewSessionManager()
+			rm := sessionmanager.NewRememberMe(sm, sessionmanager.RememberMeOptions{TTL: tc.ttl})
+			login, _ := sm.CreateSession()
+			token, err := rm.Issue(ctx, "user-1", login)
+			assert.NoError(t, err)
+
+			_, _, err = rm.Login(ctx, tc.token(token))
+			assert.True(t, errors.Is(err, sessionmanager.ErrRememberToken))
+			assert.Len(t, sm.GetAllSessions(), 1)
+
+			// A wrong token does not burn the valid one
+			if tc.ttl == 0 {
+				_, _, err = rm.Login(ctx, token)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRememberMe_Forget(t *testing.T) {
+	ctx := context.Background()
+	sm := sessionmanager.NewSessionManager()
+	rm := sessionmanager.NewRememberMe(sm, sessionmanager.RememberMeOptions{})
+	login, _ := sm.CreateSession()
+	token, err := rm.Issue(ctx, "user-1", login)
+	assert.NoError(t, err)
+
+	assert.NoError(t, rm.Forget(ctx, token))
+	_, _, err = rm.Login(ctx, token)
+	assert.True(t, errors.Is(err, sessionmanager.ErrRememberToken))
+}
+
+func TestSessionManager_Middleware_RememberMe(t *testing.T) {
+	ctx := context.Background()
+	sm := sessionmanager.NewSessionManager()
+	rm := sessionmanager.NewRememberMe(sm, sessionmanager.RememberMeOptions{})
+	login, _ := sm.CreateSession()
+	token, err := rm.Issue(ctx, "user-1", login)
+	assert.NoError(t, err)
+
+	var handled sessionmanager.ISession
+	handler := sm.Middleware(sessionmanager.MiddlewareOptions{RememberMe: rm})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled, _ = sessionmanager.SessionFromContext(r.Context())
+	}))
+	request := func(token string) map[string]*http.Cookie {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: "remember_me", Value: token})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		cookies := make(map[string]*http.Cookie)
+		for _, cookie := range w.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		return cookies
+	}
+
+	cookies := request(token)
+	subject, err := handled.Get(sessionmanager.RememberSubjectKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+	assert.Equal(t, handled.SessionId(), cookies["session_id"].Value)
+	assert.NotEqual(t, token, cookies["remember_me"].Value)
+
+	// A rejected token is deleted and the client gets an anonymous session
+	cookies = request("unknown.token")
+	assert.Equal(t, -1, cookies["remember_me"].MaxAge)
+	_, err = handled.Get(sessionmanager.RememberSubjectKey)
+	assert.Error(t, err)
+}
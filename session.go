@@ -3,6 +3,7 @@ package sessionmanager
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,6 +13,10 @@ import (
 // Session is the struct implementation for session
 // ID is the unique id for session
 // Data is the data for session
+// Version is the version of the stored session it was loaded from, the stores increase it on each save
+// RevokedReason and RevokedAt are set when the session is invalidated with Invalidate
+// CreatedAt, LastAccessedAt, AccessCount and Client describe how the session is used
+// Fingerprint is the client fingerprint the session is bound to with Bind
 type Session struct {
 	ID             string
 	Data           map[string]interface{}
@@ -19,6 +24,79 @@ type Session struct {
 	ExpirationTime time.Time
 	Expired        bool
 	Active         bool
+	Version        uint64
+	RevokedReason  RevocationReason
+	RevokedAt      time.Time
+	CreatedAt      time.Time
+	LastAccessedAt time.Time
+	AccessCount    uint64
+	Client         ClientInfo
+	Fingerprint    Fingerprint
+	observer       sessionObserver
+	quota          Quota
+	// base keeps the previous value of the keys changed since the session was loaded or saved
+	base              map[string]baseValue
+	expirationChanged bool
+	activeChanged     bool
+	clientChanged     bool
+	boundChanged      bool
+	accessChanged     bool
+	// accessSavedAt is the last access persisted in the store
+	accessSavedAt time.Time
+	// state keeps the fields of the session before the first of them changed
+	state *stateBase
+}
+
+// stateBase is the value of the fields of a session, other than the data, before they were changed
+type stateBase struct {
+	expirationTime time.Time
+	expired        bool
+	active         bool
+	revokedReason  RevocationReason
+	revokedAt      time.Time
+	client         ClientInfo
+	fingerprint    Fingerprint
+}
+
+// baseValue is the value of a key before it was changed
+type baseValue struct {
+	value   interface{}
+	existed bool
+}
+
+// sessionObserver is notified of the changes made to a session
+type sessionObserver interface {
+	// sessionChanging is called with the session lock held before changes are applied,
+	// they are recorded together and none is applied if it returns an error
+	sessionChanging(s *Session, changes []sessionChange) error
+	// sessionExpired is called once when the session is detected as expired
+	sessionExpired(s *Session)
+}
+
+// changeKind identifies the kind of change applied to a session
+type changeKind uint8
+
+const (
+	changeSet changeKind = iota + 1
+	changeDelete
+	changeExpirationTime
+	changeClear
+	changeRevoke
+	changeReactivate
+	changeClient
+	changeBind
+)
+
+// sessionChange describes a change applied to a session
+type sessionChange struct {
+	Kind           changeKind
+	Key            string
+	Value          interface{}
+	ExpirationTime time.Time
+	Reason         RevocationReason
+	RevokedAt      time.Time
+	Client         ClientInfo
+	Fingerprint    Fingerprint
 }
 
 // Verify that Session implements ISession
@@ -28,6 +106,7 @@ var _ ISession = (*Session)(nil)
 // and the session is active you can edit this values by setting the ExpirationTime and Active fields
 func NewSession(data map[string]interface{}) *Session {
 	sessionId := uuid.New().String()
+	now := time.Now()
 
 	if data == nil {
 		data = make(map[string]interface{})
@@ -38,8 +117,10 @@ func NewSession(data map[string]interface{}) *Session {
 		Data:           data,
 		m:              &sync.RWMutex{},
 		Active:         true,
-		ExpirationTime: time.Now().Add(time.Minute * 5),
+		ExpirationTime: now.Add(time.Minute * 5),
 		Expired:        false,
+		CreatedAt:      now,
+		LastAccessedAt: now,
 	}
 }
 
@@ -54,12 +135,19 @@ func (s *Session) Get(key string) (interface{}, error) {
 }
 
 // Set a value to session
+//   - It returns a *QuotaError if the value does not fit in the session quota
 func (s *Session) Set(key string, value interface{}) error {
 	s.m.Lock()
 	defer s.m.Unlock()
 	if _, ok := s.Data[key]; ok {
 		return fmt.Errorf("key %s already exists, for replace delete it first", key)
 	}
+	if err := s.checkQuota(key, value, len(s.Data)); err != nil {
+		return err
+	}
+	if err := s.notify(sessionChange{Kind: changeSet, Key: key, Value: value}); err != nil {
+		return err
+	}
 	s.Data[key] = value
 	return nil
 }
@@ -71,6 +159,9 @@ func (s *Session) Delete(key string) error {
 	if _, ok := s.Data[key]; !ok {
 		return errors.New("key not found")
 	}
+	if err := s.notify(sessionChange{Kind: changeDelete, Key: key}); err != nil {
+		return err
+	}
 	delete(s.Data, key)
 	return nil
 }
@@ -82,28 +173,37 @@ func (s *Session) SessionId() string {
 
 // SetExpirationTime sets the expiration time for session in case you
 // want to change the default expiration time
+//   - The expiration time is always changed, the session manager logs the errors
+//     recording the change
 func (s *Session) SetExpirationTime(expirationTime time.Time) {
 	s.m.Lock()
+	s.notify(sessionChange{Kind: changeExpirationTime, ExpirationTime: expirationTime})
 	s.ExpirationTime = expirationTime
 	s.m.Unlock()
 }
 
 // IsExpired returns true if the session is expired
 func (s *Session) IsExpired() bool {
-	s.m.RLock()
+	s.m.Lock()
 	if s.Expired {
+		s.m.Unlock()
 		return true
 	}
-	s.m.RUnlock()
 
-	if s.Active && time.Now().After(s.ExpirationTime) {
-		s.m.Lock()
+	justExpired := s.Active && time.Now().After(s.ExpirationTime)
+	if justExpired {
+		s.trackState()
 		s.Expired = true
 		s.Active = false
-		s.m.Unlock()
+		s.activeChanged = true
 	}
+	observer := s.observer
+	s.m.Unlock()
 
-	return s.Expired
+	if justExpired && observer != nil {
+		observer.sessionExpired(s)
+	}
+	return justExpired
 }
 
 // IsActive returns true if the session is active
@@ -112,3 +212,166 @@ func (s *Session) IsActive() bool {
 	defer s.m.RUnlock()
 	return s.Active
 }
+
+// Keys returns the sorted keys of the session data
+func (s *Session) Keys() []string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	keys := make([]string, 0, len(s.Data))
+	for key := range s.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of keys of the session data
+func (s *Session) Len() int {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return len(s.Data)
+}
+
+// All returns a copy of the session data
+func (s *Session) All() map[string]interface{} {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	data := make(map[string]interface{}, len(s.Data))
+	for key, value := range s.Data {
+		data[key] = value
+	}
+	return data
+}
+
+// Clear deletes every value of the session
+func (s *Session) Clear() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if err := s.notify(sessionChange{Kind: changeClear}); err != nil {
+		return err
+	}
+	s.Data = make(map[string]interface{})
+	return nil
+}
+
+// SetMany sets several values to session
+//   - Like Set it fails if a key already exists, in that case, if a value does not
+//     fit in the session quota or if the session manager fails to record the
+//     values none of them is set
+func (s *Session) SetMany(values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	for i, key := range keys {
+		if _, ok := s.Data[key]; ok {
+			return fmt.Errorf("key %s already exists, for replace delete it first", key)
+		}
+		if err := s.checkQuota(key, values[key], len(s.Data)+i); err != nil {
+			return err
+		}
+	}
+	changes := make([]sessionChange, len(keys))
+	for i, key := range keys {
+		changes[i] = sessionChange{Kind: changeSet, Key: key, Value: values[key]}
+	}
+	if err := s.notify(changes...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		s.Data[key] = values[key]
+	}
+	return nil
+}
+
+// DeleteMany deletes several values from session, the keys not found are ignored
+//   - None of the values is deleted if the session manager fails to record the deletions
+func (s *Session) DeleteMany(keys ...string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var changes []sessionChange
+	for _, key := range keys {
+		if _, ok := s.Data[key]; ok {
+			changes = append(changes, sessionChange{Kind: changeDelete, Key: key})
+		}
+	}
+	if err := s.notify(changes...); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		delete(s.Data, change.Key)
+	}
+	return nil
+}
+
+// notify tells the observer about changes before applying them and tracks the
+// changed keys if they are accepted, the lock must be held
+func (s *Session) notify(changes ...sessionChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if s.observer != nil {
+		if err := s.observer.sessionChanging(s, changes); err != nil {
+			return err
+		}
+	}
+	for _, change := range changes {
+		s.trackChange(change)
+	}
+	return nil
+}
+
+// trackChange tracks an accepted change, the lock must be held
+func (s *Session) trackChange(change sessionChange) {
+	switch change.Kind {
+	case changeSet, changeDelete:
+		s.track(change.Key)
+	case changeClear:
+		for key := range s.Data {
+			s.track(key)
+		}
+	case changeExpirationTime:
+		s.trackState()
+		s.expirationChanged = true
+	case changeRevoke, changeReactivate:
+		s.trackState()
+	case changeClient:
+		s.trackState()
+		s.clientChanged = true
+	case changeBind:
+		s.trackState()
+		s.boundChanged = true
+	}
+}
+
+// trackState keeps the fields of the session before the first of them changes, the lock must be held
+func (s *Session) trackState() {
+	if s.state != nil {
+		return
+	}
+	s.state = &stateBase{
+		expirationTime: s.ExpirationTime,
+		expired:        s.Expired,
+		active:         s.Active,
+		revokedReason:  s.RevokedReason,
+		revokedAt:      s.RevokedAt,
+		client:         s.Client,
+		fingerprint:    s.Fingerprint,
+	}
+}
+
+// track keeps the value of a key before its first change, the lock must be held
+func (s *Session) track(key string) {
+	if _, ok := s.base[key]; ok {
+		return
+	}
+	if s.base == nil {
+		s.base = make(map[string]baseValue)
+	}
+	value, existed := s.Data[key]
+	s.base[key] = baseValue{value: value, existed: existed}
+}
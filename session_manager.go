@@ -1,8 +1,15 @@
 package sessionmanager
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // SessionManager is the struct implementation for session manager
@@ -11,6 +18,23 @@ type SessionManager struct {
 	Sessions       map[string]ISession
 	m              *sync.RWMutex
 	AvoidExpired   bool
+	metrics        *Metrics
+	logger         atomic.Pointer[slog.Logger]
+	tracer         atomic.Pointer[tracerHolder]
+	wal            atomic.Pointer[wal]
+	store          atomic.Pointer[storeHolder]
+	nodeId         string
+	busM           sync.Mutex
+	bus            InvalidationBus
+	unsubscribe    func()
+	capacity       *capacity
+	quota          atomic.Pointer[Quota]
+	tenantsM       sync.Mutex
+	tenants        map[string]*Tenant
+	defaultMode    atomic.Int32
+	binding        atomic.Pointer[BindingPolicy]
+	// accessSaveInterval is the time.Duration set with SetAccessSaveInterval
+	accessSaveInterval atomic.Int64
 }
 
 // Verify that SessionManager implements ISessionManager
@@ -18,58 +42,271 @@ var _ ISessionManager = (*SessionManager)(nil)
 
 // NewSessionManager is the constructor for session manager
 func NewSessionManager() *SessionManager {
-	return &SessionManager{
+	sm := &SessionManager{
 		Sessions:     make(map[string]ISession),
 		m:            &sync.RWMutex{},
 		AvoidExpired: false,
+		metrics:      newMetrics(),
+		nodeId:       uuid.New().String(),
+		capacity:     newCapacity(),
 	}
+	sm.accessSaveInterval.Store(int64(defaultAccessSaveInterval))
+	return sm
 }
 
 // Get a session by session id
 func (sm *SessionManager) GetSession(sessionId string) (ISession, error) {
+	return sm.GetSessionContext(context.Background(), sessionId)
+}
+
+// GetSessionContext gets a session by session id tracing the lookup as part of the context trace
+func (sm *SessionManager) GetSessionContext(ctx context.Context, sessionId string) (ISession, error) {
+	defer sm.metrics.observe(OperationGet, time.Now())
+	ctx, span := sm.startSpan(ctx, "session.get", sessionId)
+	defer span.End()
+	if store := sm.getStore(); store != nil {
+		err := sm.loadFromStore(ctx, store, sessionId)
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			sm.metrics.lookupMisses.Add(1)
+			return nil, err
+		}
+	}
 	sm.m.RLock()
 	defer sm.m.RUnlock()
 	if session, ok := sm.Sessions[sessionId]; ok {
+		if s, ok := session.(*Session); ok {
+			if err := s.revokedError(); err != nil {
+				sm.metrics.lookupMisses.Add(1)
+				span.SetAttributes(AttributeSessionHit.Bool(false), AttributeSessionRevoked.Bool(true))
+				sm.log(slog.LevelDebug, "revoked session requested", sessionId)
+				return nil, err
+			}
+		}
 		if sm.AvoidExpired && session.IsExpired() {
-			return nil, fmt.Errorf("Session ID %s is expired", sessionId)
+			sm.metrics.lookupMisses.Add(1)
+			span.SetAttributes(AttributeSessionHit.Bool(false), AttributeSessionExpired.Bool(true))
+			sm.log(slog.LevelDebug, "expired session requested", sessionId)
+			return nil, expiredError(sessionId)
 		}
+		sm.metrics.lookupHits.Add(1)
+		sm.capacity.touch(sessionId)
+		if s, ok := session.(*Session); ok {
+			s.touch(time.Now(), time.Duration(sm.accessSaveInterval.Load()))
+		}
+		span.SetAttributes(AttributeSessionHit.Bool(true), AttributeSessionExpired.Bool(false))
 		return session, nil
 	}
-	return nil, fmt.Errorf("Session ID %s not found", sessionId)
+	sm.metrics.lookupMisses.Add(1)
+	span.SetAttributes(AttributeSessionHit.Bool(false))
+	sm.log(slog.LevelDebug, "session not found", sessionId)
+	return nil, notFoundError(sessionId)
 }
 
 // Create a new session
 func (sm *SessionManager) CreateSession() (ISession, error) {
+	return sm.CreateSessionContext(context.Background())
+}
+
+// CreateSessionContext creates a new session tracing the creation as part of the context trace
+func (sm *SessionManager) CreateSessionContext(ctx context.Context) (ISession, error) {
+	return sm.createSession(ctx, NewSession(nil), nil)
+}
+
+// createSession stores a new session, the sessions of tenant are limited by its maximum
+//   - A session rejected for lack of room is deleted from the store again
+func (sm *SessionManager) createSession(ctx context.Context, session *Session, tenant *Tenant) (ISession, error) {
+	defer sm.metrics.observe(OperationCreate, time.Now())
+	ctx, span := sm.startSpan(ctx, "session.create", "")
+	defer span.End()
+	store := sm.getStore()
+	if store != nil {
+		if err := sm.saveToStore(ctx, store, session); err != nil {
+			return nil, err
+		}
+	}
+	if err := sm.addSession(session, tenant); err != nil {
+		if store != nil {
+			if err := sm.deleteFromStore(ctx, store, session.ID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+				sm.log(slog.LevelError, "rejected session not deleted from the store", session.ID, slog.String("error", err.Error()))
+			}
+		}
+		return nil, err
+	}
+	span.SetAttributes(AttributeSessionIDHash.String(HashSessionID(session.SessionId())))
+	sm.log(slog.LevelInfo, "session created", session.SessionId(), slog.Time("expiration_time", session.ExpirationTime))
+	return session, nil
+}
+
+// addSession holds a new session in memory if there is room for it
+func (sm *SessionManager) addSession(session *Session, tenant *Tenant) error {
 	sm.m.Lock()
 	defer sm.m.Unlock()
-	session := NewSession(nil)
+	if tenant != nil {
+		if err := tenant.checkRoom(); err != nil {
+			return err
+		}
+	}
+	if err := sm.makeRoom(session.ID, true); err != nil {
+		return err
+	}
+	record := NewSessionRecord(session)
+	if err := sm.appendWAL(walEntry{Op: walCreate, SessionId: session.ID, Session: &record}); err != nil {
+		return err
+	}
+	sm.attach(session)
 	sm.Sessions[session.SessionId()] = session
-	return sm.Sessions[session.SessionId()], nil
+	sm.capacity.add(session.ID, session.ExpirationTime)
+	sm.metrics.created.Add(1)
+	return nil
 }
 
 // Destroy a session
 func (sm *SessionManager) DestroySession(sessionId string) error {
+	return sm.DestroySessionContext(context.Background(), sessionId)
+}
+
+// DestroySessionContext destroys a session tracing the removal as part of the context trace
+func (sm *SessionManager) DestroySessionContext(ctx context.Context, sessionId string) error {
+	if err := sm.destroySession(ctx, sessionId); err != nil {
+		return err
+	}
+	sm.publish(EventDestroyed, sessionId)
+	return nil
+}
+
+// destroySession removes a session from the store and from memory
+func (sm *SessionManager) destroySession(ctx context.Context, sessionId string) error {
+	defer sm.metrics.observe(OperationDestroy, time.Now())
+	ctx, span := sm.startSpan(ctx, "session.destroy", sessionId)
+	defer span.End()
+	stored := false
+	if store := sm.getStore(); store != nil {
+		err := sm.deleteFromStore(ctx, store, sessionId)
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+		stored = err == nil
+	}
 	sm.m.Lock()
 	defer sm.m.Unlock()
-	if _, ok := sm.Sessions[sessionId]; !ok {
-		return fmt.Errorf("Session ID %s not found", sessionId)
+	if _, ok := sm.Sessions[sessionId]; !ok && !stored {
+		span.SetAttributes(AttributeSessionHit.Bool(false))
+		return notFoundError(sessionId)
+	}
+	if err := sm.appendWAL(walEntry{Op: walDestroy, SessionId: sessionId}); err != nil {
+		return err
 	}
 	delete(sm.Sessions, sessionId)
+	sm.capacity.remove(sessionId)
+	sm.metrics.destroyed.Add(1)
+	span.SetAttributes(AttributeSessionHit.Bool(true))
+	sm.log(slog.LevelInfo, "session destroyed", sessionId)
+	return nil
+}
+
+// RegenerateSession replaces a session with a copy under a new session id, call it
+// when the privileges of the session change, like after a login, to prevent session fixation
+func (sm *SessionManager) RegenerateSession(sessionId string) (ISession, error) {
+	return sm.RegenerateSessionContext(context.Background(), sessionId)
+}
+
+// RegenerateSessionContext replaces a session with a copy under a new session id tracing it as part of the context trace
+//   - The data, expiration time and metadata are kept, the tenant of the session id too
+//   - The old session id is destroyed and an EventRegenerated is published for it
+//   - The regenerated session replaces the old one as default session of the manager or its tenant
+//   - With the context of a request served by Middleware, it replaces the request session
+//     and its cookie, so the middleware saves the regenerated session after the handler
+func (sm *SessionManager) RegenerateSessionContext(ctx context.Context, sessionId string) (ISession, error) {
+	ctx, span := sm.startSpan(ctx, "session.regenerate", sessionId)
+	defer span.End()
+	session, err := sm.GetSessionContext(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	old, ok := session.(*Session)
+	if !ok {
+		return nil, fmt.Errorf("Session ID %s can not be regenerated, type %T is not supported", sessionId, session)
+	}
+	record := NewSessionRecord(old)
+	record.ID = NewSession(nil).ID
+	if tenant, ok := sessionTenant(sessionId); ok {
+		record.ID = tenant + TenantSeparator + record.ID
+	}
+	record.Version = 0
+	regenerated := record.Session()
+
+	store := sm.getStore()
+	if store != nil {
+		if err := sm.saveToStore(ctx, store, regenerated); err != nil {
+			return nil, err
+		}
+	}
+	if err := sm.replaceSession(old, regenerated); err != nil {
+		if store != nil {
+			sm.deleteFromStore(ctx, store, regenerated.ID)
+		}
+		return nil, err
+	}
+	if store != nil {
+		if err := sm.deleteFromStore(ctx, store, sessionId); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return nil, err
+		}
+	}
+	if tenant := sm.tenantOf(sessionId); tenant != nil {
+		tenant.m.Lock()
+		if tenant.defaultSessionId == sessionId {
+			tenant.defaultSessionId = regenerated.ID
+		}
+		tenant.m.Unlock()
+	}
+	if rs, ok := ctx.Value(sessionContextKey{}).(*requestSession); ok {
+		rs.regenerated(sessionId, regenerated)
+	}
+	sm.publish(EventRegenerated, sessionId)
+	span.SetAttributes(AttributeSessionIDHash.String(HashSessionID(regenerated.ID)))
+	sm.log(slog.LevelInfo, "session regenerated", sessionId, slog.String("new_session", HashSessionID(regenerated.ID)))
+	return regenerated, nil
+}
+
+// replaceSession holds the regenerated session in memory instead of the old one
+func (sm *SessionManager) replaceSession(old, regenerated *Session) error {
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	record := NewSessionRecord(regenerated)
+	if err := sm.appendWAL(walEntry{Op: walRegenerate, SessionId: old.ID, Session: &record}); err != nil {
+		return err
+	}
+	sm.regenerated(old.ID, regenerated)
 	return nil
 }
 
+// regenerated replaces the old session id with the regenerated session, the lock must be held
+func (sm *SessionManager) regenerated(oldId string, regenerated *Session) {
+	sm.attach(regenerated)
+	sm.Sessions[regenerated.ID] = regenerated
+	sm.capacity.add(regenerated.ID, regenerated.ExpirationTime)
+	if sm.DefaultSession != nil && sm.DefaultSession.SessionId() == oldId {
+		sm.DefaultSession = regenerated
+	}
+	delete(sm.Sessions, oldId)
+	sm.capacity.remove(oldId)
+}
+
 // SetAsDefaultSession sets the default session for not require session id for get a current session
 func (sm *SessionManager) SetAsDefaultSession(sessionId string) error {
 	sm.m.Lock()
 	defer sm.m.Unlock()
 	if session, ok := sm.Sessions[sessionId]; ok {
 		if sm.AvoidExpired && session.IsExpired() {
-			return fmt.Errorf("Session ID %s is expired", sessionId)
+			return expiredError(sessionId)
+		}
+		if err := sm.appendWAL(walEntry{Op: walDefault, SessionId: sessionId}); err != nil {
+			return err
 		}
 		sm.DefaultSession = session
 		return nil
 	}
-	return fmt.Errorf("Session ID %s not found", sessionId)
+	return notFoundError(sessionId)
 }
 
 // GetDefaultSession gets the default session for not require session id
@@ -80,8 +317,13 @@ func (sm *SessionManager) GetDefaultSession() (ISession, error) {
 		return nil, fmt.Errorf("default session not set")
 	}
 
+	if s, ok := sm.DefaultSession.(*Session); ok {
+		if err := s.revokedError(); err != nil {
+			return nil, err
+		}
+	}
 	if sm.AvoidExpired && sm.DefaultSession.IsExpired() {
-		return nil, fmt.Errorf("default session is expired")
+		return nil, &sessionError{msg: "default session is expired", err: ErrSessionExpired}
 	}
 	return sm.DefaultSession, nil
 }
@@ -97,12 +339,39 @@ func (sm *SessionManager) GetAllSessions() map[string]ISession {
 }
 
 // DestroyAllSessions destroys all sessions stored in session manager
-//   - Important: this method never fails, but in future it can be changed
+//   - Important: this method only fails if the store or the write-ahead log can not record it
 func (sm *SessionManager) DestroyAllSessions() error {
+	destroyed, err := sm.destroyAllSessions()
+	for _, sessionId := range destroyed {
+		sm.publish(EventDestroyed, sessionId)
+	}
+	return err
+}
+
+// destroyAllSessions removes every session held in memory and returns their ids
+func (sm *SessionManager) destroyAllSessions() ([]string, error) {
 	sm.m.Lock()
 	defer sm.m.Unlock()
+	if store := sm.getStore(); store != nil {
+		for sessionId := range sm.Sessions {
+			err := sm.deleteFromStore(context.Background(), store, sessionId)
+			if err != nil && !errors.Is(err, ErrSessionNotFound) {
+				return nil, err
+			}
+		}
+	}
+	if err := sm.appendWAL(walEntry{Op: walDestroyAll}); err != nil {
+		return nil, err
+	}
+	destroyed := make([]string, 0, len(sm.Sessions))
+	for sessionId := range sm.Sessions {
+		destroyed = append(destroyed, sessionId)
+		sm.log(slog.LevelInfo, "session destroyed", sessionId)
+	}
+	sm.metrics.destroyed.Add(uint64(len(destroyed)))
 	sm.Sessions = make(map[string]ISession)
-	return nil
+	sm.capacity.reset()
+	return destroyed, nil
 }
 
 // SetAvoidExpired sets the avoid expired flag
@@ -113,3 +382,44 @@ func (sm *SessionManager) SetAvoidExpired(avoidExpired bool) {
 	defer sm.m.Unlock()
 	sm.AvoidExpired = avoidExpired
 }
+
+// attach makes a session report its changes to the session manager and applies the manager or tenant quota
+func (sm *SessionManager) attach(s *Session) {
+	s.observer = sm
+	if quota := sm.quotaOf(s.ID); quota != nil {
+		s.quota = *quota
+	}
+}
+
+// sessionChanging is called by the sessions of the manager before applying changes,
+// several changes are recorded in a single log entry so they are replayed together
+func (sm *SessionManager) sessionChanging(s *Session, changes []sessionChange) error {
+	for _, change := range changes {
+		// SetExpirationTime applies the change even if it is not recorded
+		if change.Kind == changeExpirationTime {
+			sm.capacity.expirationChanged(s.ID, change.ExpirationTime)
+		}
+	}
+	entry := walEntry{Op: walChanges, SessionId: s.ID, Changes: changes}
+	if len(changes) == 1 {
+		entry = walEntry{Op: walChange, SessionId: s.ID, Change: changes[0]}
+	}
+	if err := sm.appendWAL(entry); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		switch change.Kind {
+		case changeRevoke:
+			sm.log(slog.LevelInfo, "session revoked", s.ID, slog.String("reason", string(change.Reason)))
+		case changeReactivate:
+			sm.log(slog.LevelInfo, "session reactivated", s.ID)
+		}
+	}
+	return nil
+}
+
+// sessionExpired is called by the sessions of the manager when they expire
+func (sm *SessionManager) sessionExpired(s *Session) {
+	sm.metrics.expired.Add(1)
+	sm.log(slog.LevelInfo, "session expired", s.SessionId())
+}
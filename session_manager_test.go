@@ -211,6 +211,65 @@ func TestSessionManager_DestroySession(t *testing.T) {
 	}
 }
 
+func TestSessionManager_RegenerateSession(t *testing.T) {
+	cases := map[string]struct {
+		prepare func(t *testing.T, sm *sessionmanager.SessionManager) sessionmanager.ISessionManager
+	}{
+		"memory": {
+			prepare: func(t *testing.T, sm *sessionmanager.SessionManager) sessionmanager.ISessionManager {
+				return sm
+			},
+		},
+
+		"store": {
+			prepare: func(t *testing.T, sm *sessionmanager.SessionManager) sessionmanager.ISessionManager {
+				store := newBoltStore(t)
+				sm.SetStore(store)
+				return sm
+			},
+		},
+
+		"tenant": {
+			prepare: func(t *testing.T, sm *sessionmanager.SessionManager) sessionmanager.ISessionManager {
+				acme, _ := sm.ForTenant("acme")
+				return acme
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			manager := tc.prepare(t, sm)
+			old, err := manager.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, old.Set("user", "solrac"))
+			assert.NoError(t, sm.SaveSession(old))
+			assert.NoError(t, manager.SetAsDefaultSession(old.SessionId()))
+
+			var s sessionmanager.ISession
+			if tenant, ok := manager.(*sessionmanager.Tenant); ok {
+				s, err = tenant.RegenerateSession(old.SessionId())
+			} else {
+				s, err = sm.RegenerateSession(old.SessionId())
+			}
+			assert.NoError(t, err)
+			assert.NotEqual(t, old.SessionId(), s.SessionId())
+			assert.Equal(t, old.All(), s.All())
+			assert.True(t, old.Metadata().ExpirationTime.Equal(s.Metadata().ExpirationTime))
+
+			_, err = manager.GetSession(old.SessionId())
+			assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+			loaded, err := manager.GetSession(s.SessionId())
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{"user": "solrac"}, loaded.All())
+			defaultSession, err := manager.GetDefaultSession()
+			assert.NoError(t, err)
+			assert.Equal(t, s.SessionId(), defaultSession.SessionId())
+		})
+	}
+}
+
 func TestSessionManager_DestroyAllSessions(t *testing.T) {
 	cases := map[string]struct {
 		sessions func(id string, session sessionmanager.ISession) map[string]sessionmanager.ISession
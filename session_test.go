@@ -2,6 +2,8 @@ package sessionmanager_test
 
 import (
 	"errors"
+	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -291,3 +293,69 @@ func TestSessionManager_IsActive(t *testing.T) {
 		})
 	}
 }
+
+func TestSession_BulkOperations(t *testing.T) {
+	cases := map[string]struct {
+		action   func(s sessionmanager.ISession) error
+		err      bool
+		expected map[string]interface{}
+	}{
+		"set many": {
+			action: func(s sessionmanager.ISession) error {
+				return s.SetMany(map[string]interface{}{"age": 20, "city": "Lima"})
+			},
+			expected: map[string]interface{}{"user": "solrac", "cart": 2, "age": 20, "city": "Lima"},
+		},
+
+		"set many with existing key": {
+			action: func(s sessionmanager.ISession) error {
+				return s.SetMany(map[string]interface{}{"age": 20, "user": "other"})
+			},
+			err:      true,
+			expected: map[string]interface{}{"user": "solrac", "cart": 2},
+		},
+
+		"delete many": {
+			action: func(s sessionmanager.ISession) error {
+				return s.DeleteMany("user", "missing")
+			},
+			expected: map[string]interface{}{"cart": 2},
+		},
+
+		"clear": {
+			action: func(s sessionmanager.ISession) error {
+				return s.Clear()
+			},
+			expected: map[string]interface{}{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var s sessionmanager.ISession = sessionmanager.NewSession(map[string]interface{}{"user": "solrac", "cart": 2})
+
+			err := tc.action(s)
+			if (err != nil) != tc.err {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			all := s.All()
+			if !reflect.DeepEqual(all, tc.expected) {
+				t.Errorf("Session data is not equal to expected data. Expected: %v, Actual: %v", tc.expected, all)
+			}
+			if s.Len() != len(tc.expected) || len(s.Keys()) != len(tc.expected) {
+				t.Errorf("Session length is not equal to expected length. Expected: %d, Actual: %d", len(tc.expected), s.Len())
+			}
+			keys := s.Keys()
+			if !sort.StringsAreSorted(keys) {
+				t.Errorf("Session keys are not sorted: %v", keys)
+			}
+
+			// All returns a copy
+			all["copy"] = true
+			if _, err := s.Get("copy"); err == nil {
+				t.Error("Changing the copy changed the session")
+			}
+		})
+	}
+}
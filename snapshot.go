@@ -0,0 +1,135 @@
+package sessionmanager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Snapshot
+const SnapshotVersion uint16 = 1
+
+// snapshotMagic identifies the snapshot files
+var snapshotMagic = [4]byte{'S', 'M', 'S', 'N'}
+
+var (
+	// ErrInvalidSnapshot is returned when restoring data that is not a snapshot
+	ErrInvalidSnapshot = errors.New("invalid session snapshot")
+	// ErrSnapshotChecksum is returned when the snapshot content does not match its checksum
+	ErrSnapshotChecksum = errors.New("session snapshot checksum mismatch")
+)
+
+// snapshotHeader precedes the snapshot payload
+type snapshotHeader struct {
+	Magic    [4]byte
+	Version  uint16
+	Length   uint64
+	Checksum uint32
+}
+
+// snapshot is the payload of a snapshot
+type snapshot struct {
+	Sessions         []SessionRecord
+	DefaultSessionId string
+}
+
+// Snapshot writes every session of the session manager to w
+//   - The snapshot is versioned and checksummed, it can be loaded with Restore
+//   - The default session is preserved if it is one of the stored sessions
+func (sm *SessionManager) Snapshot(w io.Writer) error {
+	sm.m.RLock()
+	payload := snapshot{Sessions: make([]SessionRecord, 0, len(sm.Sessions))}
+	for _, session := range sm.Sessions {
+		s, ok := session.(*Session)
+		if !ok {
+			sm.m.RUnlock()
+			return fmt.Errorf("Session ID %s can not be snapshotted, type %T is not supported", session.SessionId(), session)
+		}
+		payload.Sessions = append(payload.Sessions, NewSessionRecord(s))
+	}
+	if sm.DefaultSession != nil {
+		payload.DefaultSessionId = sm.DefaultSession.SessionId()
+	}
+	sm.m.RUnlock()
+
+	data, err := encodeGob(payload)
+	if err != nil {
+		return fmt.Errorf("encoding session snapshot: %w", err)
+	}
+
+	header := snapshotHeader{
+		Magic:    snapshotMagic,
+		Version:  SnapshotVersion,
+		Length:   uint64(len(data)),
+		Checksum: crc32.ChecksumIEEE(data),
+	}
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Restore loads the sessions written by Snapshot into the session manager
+//   - Sessions already expired are skipped
+//   - Restored sessions replace the stored sessions with the same id
+//   - The default session is restored if it was not skipped
+func (sm *SessionManager) Restore(r io.Reader) error {
+	var header snapshotHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSnapshot, err)
+	}
+	if header.Magic != snapshotMagic {
+		return ErrInvalidSnapshot
+	}
+	if header.Version != SnapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, header.Version)
+	}
+
+	// Avoid allocating the length written in the header before knowing the data is there
+	data, err := io.ReadAll(io.LimitReader(r, int64(header.Length)))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSnapshot, err)
+	}
+	if uint64(len(data)) != header.Length {
+		return fmt.Errorf("%w: unexpected end of snapshot", ErrInvalidSnapshot)
+	}
+	if crc32.ChecksumIEEE(data) != header.Checksum {
+		return ErrSnapshotChecksum
+	}
+
+	var payload snapshot
+	if err := decodeGob(data, &payload); err != nil {
+		return fmt.Errorf("decoding session snapshot: %w", err)
+	}
+
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	if sm.Sessions == nil {
+		sm.Sessions = make(map[string]ISession)
+	}
+	for _, record := range payload.Sessions {
+		if record.IsExpired() {
+			continue
+		}
+		session := record.Session()
+		if err := sm.makeRoom(session.ID, false); err != nil {
+			return err
+		}
+		if err := sm.appendWAL(walEntry{Op: walCreate, SessionId: session.ID, Session: &record}); err != nil {
+			return err
+		}
+		sm.attach(session)
+		sm.Sessions[session.ID] = session
+		sm.capacity.add(session.ID, session.ExpirationTime)
+		if session.ID == payload.DefaultSessionId {
+			if err := sm.appendWAL(walEntry{Op: walDefault, SessionId: session.ID}); err != nil {
+				return err
+			}
+			sm.DefaultSession = session
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package sessionmanager_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_SnapshotAndRestore(t *testing.T) {
+	cases := map[string]struct {
+		setup    func(sm *sessionmanager.SessionManager) []string
+		corrupt  func(data []byte) []byte
+		expected int
+		err      error
+	}{
+		"empty": {
+			setup:    func(sm *sessionmanager.SessionManager) []string { return nil },
+			expected: 0,
+		},
+
+		"with data and default session": {
+			setup: func(sm *sessionmanager.SessionManager) []string {
+				s1, _ := sm.CreateSession()
+				s1.Set("user", "solrac")
+				s1.Set("age", 20)
+				s2, _ := sm.CreateSession()
+				sm.SetAsDefaultSession(s2.SessionId())
+				return []string{s1.SessionId(), s2.SessionId()}
+			},
+			expected: 2,
+		},
+
+		"skip expired sessions": {
+			setup: func(sm *sessionmanager.SessionManager) []string {
+				s1, _ := sm.CreateSession()
+				s2, _ := sm.CreateSession()
+				s2.SetExpirationTime(time.Now().Add(-1 * time.Minute))
+				return []string{s1.SessionId()}
+			},
+			expected: 1,
+		},
+
+		"corrupted content": {
+			setup: func(sm *sessionmanager.SessionManager) []string {
+				s, _ := sm.CreateSession()
+				s.Set("user", "solrac")
+				return nil
+			},
+			corrupt: func(data []byte) []byte {
+				data[len(data)-1] ^= 0xff
+				return data
+			},
+			err: sessionmanager.ErrSnapshotChecksum,
+		},
+
+		"truncated": {
+			setup: func(sm *sessionmanager.SessionManager) []string {
+				sm.CreateSession()
+				return nil
+			},
+			corrupt: func(data []byte) []byte { return data[:len(data)-10] },
+			err:     sessionmanager.ErrInvalidSnapshot,
+		},
+
+		"not a snapshot": {
+			setup:   func(sm *sessionmanager.SessionManager) []string { return nil },
+			corrupt: func(data []byte) []byte { return []byte("this is not a snapshot at all") },
+			err:     sessionmanager.ErrInvalidSnapshot,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := sessionmanager.NewSessionManager()
+			ids := tc.setup(source)
+
+			var buf bytes.Buffer
+			err := source.Snapshot(&buf)
+			assert.NoError(t, err)
+
+			data := buf.Bytes()
+			if tc.corrupt != nil {
+				data = tc.corrupt(data)
+			}
+
+			target := sessionmanager.NewSessionManager()
+			err = target.Restore(bytes.NewReader(data))
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "expected %s, actual %v", tc.err, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, target.Sessions, tc.expected)
+
+			for _, id := range ids {
+				original, _ := source.GetSession(id)
+				restored, err := target.GetSession(id)
+				assert.NoError(t, err)
+				assert.Equal(t, original.(*sessionmanager.Session).Data, restored.(*sessionmanager.Session).Data)
+				assert.True(t, original.(*sessionmanager.Session).ExpirationTime.Equal(restored.(*sessionmanager.Session).ExpirationTime))
+				assert.Equal(t, original.IsActive(), restored.IsActive())
+			}
+
+			if source.DefaultSession != nil {
+				assert.Equal(t, source.DefaultSession.SessionId(), target.DefaultSession.SessionId())
+				assert.Same(t, target.Sessions[source.DefaultSession.SessionId()], target.DefaultSession)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package sessionmanager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SQLDialect identifies the SQL syntax used by a SQLStore
+type SQLDialect int
+
+const (
+	// DialectSQLite uses ? placeholders and ON CONFLICT upserts
+	DialectSQLite SQLDialect = iota
+	// DialectPostgres uses $n placeholders and ON CONFLICT upserts
+	DialectPostgres
+	// DialectMySQL uses ? placeholders and ON DUPLICATE KEY upserts
+	DialectMySQL
+)
+
+// sqlIdentifier validates the table names, they can not be passed as query arguments
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStoreOptions configures a SQLStore
+type SQLStoreOptions struct {
+	// Dialect of the database, by default DialectSQLite
+	Dialect SQLDialect
+	// Table where the sessions are stored, by default "sessions"
+	Table string
+	// CleanupBatchSize is the number of expired rows deleted per statement, by default 500
+	CleanupBatchSize int
+}
+
+// SQLStore is a store that keeps the sessions in a table of a database/sql database
+//   - The data is encoded with encoding/gob in a single column
+//   - The expiration time is stored in an indexed column as unix milliseconds
+//     so the expired rows can be deleted in batches with DeleteExpired
+type SQLStore struct {
+	db      *sql.DB
+	opts    SQLStoreOptions
+	queries sqlQueries
+}
+
+// sqlQueries are the statements of a store built for its dialect and table
+type sqlQueries struct {
+	schema        []string
+	load          string
+	save          string
+	update        string
+	delete        string
+	deleteExpired string
+}
+
+// Verify that SQLStore implements Store
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore is the constructor for sql store, call Migrate to create its table
+func NewSQLStore(db *sql.DB, opts SQLStoreOptions) (*SQLStore, error) {
+	if opts.Table == "" {
+		opts.Table = "sessions"
+	}
+	if !sqlIdentifier.MatchString(opts.Table) {
+		return nil, fmt.Errorf("invalid table name %q", opts.Table)
+	}
+	if opts.CleanupBatchSize <= 0 {
+		opts.CleanupBatchSize = 500
+	}
+
+	queries, err := newSQLQueries(opts.Dialect, opts.Table, opts.CleanupBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db, opts: opts, queries: queries}, nil
+}
+
+// Schema returns the statements that create the sessions table and its expiration index
+func (ss *SQLStore) Schema() []string {
+	return ss.queries.schema
+}
+
+// Migrate creates the sessions table and its expiration index if they do not exist
+func (ss *SQLStore) Migrate(ctx context.Context) error {
+	for _, statement := range ss.queries.schema {
+		if _, err := ss.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migrating sessions table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Load a session from its row, expired rows are reported as not found
+func (ss *SQLStore) Load(ctx context.Context, sessionId string) (*Session, error) {
+	var (
+		data           []byte
+		expirationTime int64
+		active         int
+		expired        int
+		version        uint64
+		revokedReason  string
+		revokedAt      int64
+		createdAt      int64
+		lastAccessedAt int64
+		record         = SessionRecord{ID: sessionId}
+	)
+	row := ss.db.QueryRowContext(ctx, ss.queries.load, sessionId, time.Now().UnixMilli())
+	err := row.Scan(&data, &expirationTime, &active, &expired, &version, &revokedReason, &revokedAt,
+		&createdAt, &lastAccessedAt, &record.AccessCount, &record.Client.IP, &record.Client.UserAgent, &record.Client.Device,
+		&record.Fingerprint.IP, &record.Fingerprint.UserAgent, &record.Fingerprint.Certificate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	record.ExpirationTime = time.UnixMilli(expirationTime)
+	record.Active = active == 1
+	record.Expired = expired == 1
+	record.Version = version
+	record.RevokedReason = RevocationReason(revokedReason)
+	record.RevokedAt = sqlTime(revokedAt)
+	record.CreatedAt = sqlTime(createdAt)
+	record.LastAccessedAt = sqlTime(lastAccessedAt)
+	if err := decodeGob(data, &record.Data); err != nil {
+		return nil, fmt.Errorf("decoding session data: %w", err)
+	}
+	return record.Session(), nil
+}
+
+// Save inserts or replaces the row of the session, expired sessions are deleted instead
+//   - New sessions are inserted, the row is only replaced if it has the version of the
+//     session, otherwise it returns ErrVersionConflict
+//   - A saved session whose row was deleted returns ErrVersionConflict, it is not inserted again
+func (ss *SQLStore) Save(ctx context.Context, s *Session) error {
+	record := NewSessionRecord(s)
+	if record.IsExpired() {
+		_, err := ss.db.ExecContext(ctx, ss.queries.delete, record.ID)
+		return err
+	}
+
+	data, err := encodeGob(record.Data)
+	if err != nil {
+		return fmt.Errorf("encoding session data: %w", err)
+	}
+	args := []interface{}{
+		record.ID, data, record.ExpirationTime.UnixMilli(), sqlBool(record.Active), sqlBool(record.Expired), record.Version + 1,
+		string(record.RevokedReason), unixMilliOrZero(record.RevokedAt),
+		unixMilliOrZero(record.CreatedAt), unixMilliOrZero(record.LastAccessedAt), record.AccessCount,
+		record.Client.IP, record.Client.UserAgent, record.Client.Device,
+		record.Fingerprint.IP, record.Fingerprint.UserAgent, record.Fingerprint.Certificate,
+	}
+	query := ss.queries.save
+	if record.Version > 0 {
+		query = ss.queries.update
+		args = append(args[1:], record.ID, record.Version)
+	}
+	result, err := ss.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return VersionConflict(record.ID, record.Version)
+	}
+	s.Saved(record.Version + 1)
+	return nil
+}
+
+// Delete removes the row of the session
+func (ss *SQLStore) Delete(ctx context.Context, sessionId string) error {
+	result, err := ss.db.ExecContext(ctx, ss.queries.delete, sessionId)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteExpired deletes the expired rows in batches of CleanupBatchSize
+// so the table is not locked for long, returns the number of deleted rows
+func (ss *SQLStore) DeleteExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		result, err := ss.db.ExecContext(ctx, ss.queries.deleteExpired, time.Now().UnixMilli())
+		if err != nil {
+			return total, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
+		if affected < int64(ss.opts.CleanupBatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// sqlColumns are the columns of the sessions table in the order of the queries arguments
+var sqlColumns = []string{
+	"id", "data", "expires_at", "active", "expired", "version", "revoked_reason", "revoked_at",
+	"created_at", "last_accessed_at", "access_count", "client_ip", "client_user_agent", "client_device",
+	"fingerprint_ip", "fingerprint_user_agent", "fingerprint_certificate",
+}
+
+// newSQLQueries builds the statements for the dialect and table
+func newSQLQueries(dialect SQLDialect, table string, batchSize int) (sqlQueries, error) {
+	columns := strings.Join(sqlColumns, ", ")
+	// The upserts of the new sessions replace every column but id when the stored version
+	// is the previous one, the saved sessions are updated only when their row has their version
+	var updates []string
+	switch dialect {
+	case DialectSQLite, DialectPostgres:
+		for _, column := range sqlColumns[1:] {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	case DialectMySQL:
+		// MySQL upserts can not be conditional, so every column keeps its value
+		// unless the version matches, the version is assigned last
+		for _, column := range sqlColumns[1:] {
+			if column != "version" {
+				updates = append(updates, fmt.Sprintf("%s = IF(version = VALUES(version) - 1, VALUES(%s), %s)", column, column, column))
+			}
+		}
+		updates = append(updates, "version = IF(version = VALUES(version) - 1, VALUES(version), version)")
+	}
+	const columnTypes = "expires_at BIGINT NOT NULL, active SMALLINT NOT NULL, expired SMALLINT NOT NULL, " +
+		"version BIGINT NOT NULL DEFAULT 0, revoked_reason VARCHAR(255) NOT NULL DEFAULT '', revoked_at BIGINT NOT NULL DEFAULT 0, " +
+		"created_at BIGINT NOT NULL DEFAULT 0, last_accessed_at BIGINT NOT NULL DEFAULT 0, access_count BIGINT NOT NULL DEFAULT 0, " +
+		"client_ip VARCHAR(64) NOT NULL DEFAULT '', client_user_agent VARCHAR(512) NOT NULL DEFAULT '', client_device VARCHAR(255) NOT NULL DEFAULT '', " +
+		"fingerprint_ip VARCHAR(64) NOT NULL DEFAULT '', fingerprint_user_agent VARCHAR(64) NOT NULL DEFAULT '', fingerprint_certificate VARCHAR(64) NOT NULL DEFAULT ''"
+
+	switch dialect {
+	case DialectSQLite:
+		return sqlQueries{
+			schema: []string{
+				fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(255) PRIMARY KEY, data BLOB NOT NULL, %s)", table, columnTypes),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at_idx ON %s (expires_at)", table, table),
+			},
+			load:          fmt.Sprintf("SELECT %s FROM %s WHERE id = ? AND expires_at > ?", strings.Join(sqlColumns[1:], ", "), table),
+			save:          fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s WHERE %s.version = excluded.version - 1", table, columns, sqlPlaceholders(dialect, len(sqlColumns)), strings.Join(updates, ", "), table),
+			update:        sqlUpdate(dialect, table),
+			delete:        fmt.Sprintf("DELETE FROM %s WHERE id = ?", table),
+			deleteExpired: fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE expires_at <= ? LIMIT %d)", table, table, batchSize),
+		}, nil
+	case DialectPostgres:
+		return sqlQueries{
+			schema: []string{
+				fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(255) PRIMARY KEY, data BYTEA NOT NULL, %s)", table, columnTypes),
+				fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at_idx ON %s (expires_at)", table, table),
+			},
+			load:          fmt.Sprintf("SELECT %s FROM %s WHERE id = $1 AND expires_at > $2", strings.Join(sqlColumns[1:], ", "), table),
+			save:          fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s WHERE %s.version = excluded.version - 1", table, columns, sqlPlaceholders(dialect, len(sqlColumns)), strings.Join(updates, ", "), table),
+			update:        sqlUpdate(dialect, table),
+			delete:        fmt.Sprintf("DELETE FROM %s WHERE id = $1", table),
+			deleteExpired: fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE expires_at <= $1 LIMIT %d)", table, table, batchSize),
+		}, nil
+	case DialectMySQL:
+		return sqlQueries{
+			schema: []string{
+				fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(255) PRIMARY KEY, data LONGBLOB NOT NULL, %s, INDEX %s_expires_at_idx (expires_at))", table, columnTypes, table),
+			},
+			load:          fmt.Sprintf("SELECT %s FROM %s WHERE id = ? AND expires_at > ?", strings.Join(sqlColumns[1:], ", "), table),
+			save:          fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s", table, columns, sqlPlaceholders(dialect, len(sqlColumns)), strings.Join(updates, ", ")),
+			update:        sqlUpdate(dialect, table),
+			delete:        fmt.Sprintf("DELETE FROM %s WHERE id = ?", table),
+			deleteExpired: fmt.Sprintf("DELETE FROM %s WHERE expires_at <= ? LIMIT %d", table, batchSize),
+		}, nil
+	}
+	return sqlQueries{}, fmt.Errorf("unknown SQL dialect %d", dialect)
+}
+
+// sqlUpdate returns the statement replacing every column but id of the row
+// with the given id and version, the id and the version are the last arguments
+func sqlUpdate(dialect SQLDialect, table string) string {
+	placeholders := strings.Split(sqlPlaceholders(dialect, len(sqlColumns)+1), ", ")
+	sets := make([]string, len(sqlColumns)-1)
+	for i, column := range sqlColumns[1:] {
+		sets[i] = fmt.Sprintf("%s = %s", column, placeholders[i])
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE id = %s AND version = %s", table, strings.Join(sets, ", "), placeholders[len(sets)], placeholders[len(sets)+1])
+}
+
+// sqlPlaceholders returns n comma separated placeholders of the dialect
+func sqlPlaceholders(dialect SQLDialect, n int) string {
+	placeholders := make([]string, n)
+	for i := range placeholders {
+		placeholders[i] = "?"
+		if dialect == DialectPostgres {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// sqlTime decodes a unix milliseconds column, 0 is the zero time
+func sqlTime(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+// unixMilliOrZero returns the unix milliseconds of t or 0 if t is the zero time
+func unixMilliOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// sqlBool encodes a bool as a SMALLINT column value
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
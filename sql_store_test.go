@@ -0,0 +1,224 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func newSQLiteStore(t *testing.T, opts sessionmanager.SQLStoreOptions) (*sessionmanager.SQLStore, *sql.DB) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := sessionmanager.NewSQLStore(db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store, db
+}
+
+func TestNewSQLStore(t *testing.T) {
+	cases := map[string]struct {
+		opts        sessionmanager.SQLStoreOptions
+		placeholder string
+		err         bool
+	}{
+		"sqlite": {
+			opts:        sessionmanager.SQLStoreOptions{Dialect: sessionmanager.DialectSQLite},
+			placeholder: "?",
+		},
+
+		"postgres": {
+			opts:        sessionmanager.SQLStoreOptions{Dialect: sessionmanager.DialectPostgres},
+			placeholder: "$1",
+		},
+
+		"mysql": {
+			opts:        sessionmanager.SQLStoreOptions{Dialect: sessionmanager.DialectMySQL, Table: "app_sessions"},
+			placeholder: "?",
+		},
+
+		"invalid table": {
+			opts: sessionmanager.SQLStoreOptions{Table: "sessions; DROP TABLE users"},
+			err:  true,
+		},
+
+		"unknown dialect": {
+			opts: sessionmanager.SQLStoreOptions{Dialect: sessionmanager.SQLDialect(42)},
+			err:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, err := sessionmanager.NewSQLStore(nil, tc.opts)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			schema := strings.Join(store.Schema(), "\n")
+			assert.Contains(t, schema, "expires_at_idx")
+			if tc.opts.Table != "" {
+				assert.Contains(t, schema, tc.opts.Table)
+			}
+			if tc.opts.Dialect == sessionmanager.DialectPostgres {
+				assert.Contains(t, schema, "BYTEA")
+			}
+		})
+	}
+}
+
+func TestSQLStore_SaveAndLoad(t *testing.T) {
+	cases := map[string]struct {
+		data       map[string]interface{}
+		expiration time.Duration
+		update     map[string]interface{}
+		err        error
+	}{
+		"empty": {
+			data:       map[string]interface{}{},
+			expiration: time.Hour,
+		},
+
+		"with data": {
+			data: map[string]interface{}{
+				"user":  "solrac",
+				"age":   20,
+				"roles": []string{"admin"},
+			},
+			expiration: time.Hour,
+		},
+
+		"saved twice": {
+			data:       map[string]interface{}{"user": "solrac"},
+			expiration: time.Hour,
+			update:     map[string]interface{}{"cart": 3},
+		},
+
+		"expired": {
+			data:       map[string]interface{}{"user": "solrac"},
+			expiration: -time.Minute,
+			err:        sessionmanager.ErrSessionNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, _ := newSQLiteStore(t, sessionmanager.SQLStoreOptions{})
+			ctx := context.Background()
+
+			session := sessionmanager.NewSession(tc.data)
+			expiration := time.Now().Add(tc.expiration)
+			session.SetExpirationTime(expiration)
+			assert.NoError(t, store.Save(ctx, session))
+
+			for key, value := range tc.update {
+				session.Set(key, value)
+				tc.data[key] = value
+			}
+			if tc.update != nil {
+				assert.NoError(t, store.Save(ctx, session))
+			}
+
+			loaded, err := store.Load(ctx, session.SessionId())
+			assert.Equal(t, tc.err, err)
+			if tc.err != nil {
+				return
+			}
+			assert.Equal(t, tc.data, loaded.Data)
+			assert.Equal(t, expiration.UnixMilli(), loaded.ExpirationTime.UnixMilli())
+			assert.True(t, loaded.IsActive())
+		})
+	}
+}
+
+func TestSQLStore_Delete(t *testing.T) {
+	cases := map[string]struct {
+		saved bool
+		err   error
+	}{
+		"saved": {
+			saved: true,
+		},
+
+		"not found": {
+			err: sessionmanager.ErrSessionNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, _ := newSQLiteStore(t, sessionmanager.SQLStoreOptions{})
+			session := sessionmanager.NewSession(nil)
+			if tc.saved {
+				assert.NoError(t, store.Save(context.Background(), session))
+			}
+
+			assert.Equal(t, tc.err, store.Delete(context.Background(), session.SessionId()))
+		})
+	}
+}
+
+func TestSQLStore_DeleteExpired(t *testing.T) {
+	cases := map[string]struct {
+		expired   int
+		valid     int
+		batchSize int
+	}{
+		"nothing expired": {
+			valid:     3,
+			batchSize: 2,
+		},
+
+		"less than a batch": {
+			expired:   1,
+			valid:     2,
+			batchSize: 5,
+		},
+
+		"several batches": {
+			expired:   7,
+			valid:     2,
+			batchSize: 3,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			store, db := newSQLiteStore(t, sessionmanager.SQLStoreOptions{CleanupBatchSize: tc.batchSize})
+			ctx := context.Background()
+
+			for i := 0; i < tc.expired+tc.valid; i++ {
+				session := sessionmanager.NewSession(nil)
+				session.SetExpirationTime(time.Now().Add(time.Hour))
+				assert.NoError(t, store.Save(ctx, session))
+				if i < tc.expired {
+					_, err := db.Exec("UPDATE sessions SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute).UnixMilli(), session.ID)
+					assert.NoError(t, err)
+				}
+			}
+
+			deleted, err := store.DeleteExpired(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(tc.expired), deleted)
+
+			var remaining int
+			assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&remaining))
+			assert.Equal(t, tc.valid, remaining)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package sessionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrSessionNotFound is returned by the stores when a session does not exist
+var ErrSessionNotFound = errors.New("session not found")
+
+// Store persists the sessions of a session manager outside of the process memory
+//   - Implementations persist the SessionRecord of a session and call Saved after writing it,
+//     returning VersionConflict when the stored version differs, see the boltstore package
+type Store interface {
+	// Load a session by session id, returns ErrSessionNotFound if it does not exist or is expired
+	Load(ctx context.Context, sessionId string) (*Session, error)
+	// Save a session replacing the stored one
+	Save(ctx context.Context, s *Session) error
+	// Delete a session by session id, returns ErrSessionNotFound if it does not exist
+	Delete(ctx context.Context, sessionId string) error
+}
+
+// storeHolder allows to store the store interface in an atomic pointer
+type storeHolder struct {
+	store Store
+}
+
+// SetStore sets the store where the sessions are persisted
+//   - CreateSession saves the new sessions and DestroySession deletes them from the store
+//   - GetSession always loads the session from the store, so changes made by other processes are seen
+//   - Changes made to a session are persisted calling SaveSession
+//   - GetAllSessions and DestroyAllSessions only include the sessions loaded by this session manager
+func (sm *SessionManager) SetStore(store Store) {
+	if store == nil {
+		sm.store.Store(nil)
+		return
+	}
+	sm.store.Store(&storeHolder{store: store})
+}
+
+// SaveSession persists the changes made to a session in the store
+//   - Without a store the sessions only live in memory and nothing is done
+//   - A stored session is not written again if it did not change since it was loaded or saved
+func (sm *SessionManager) SaveSession(s ISession) error {
+	return sm.SaveSessionContext(context.Background(), s)
+}
+
+// SaveSessionContext persists the changes made to a session tracing the save as part of the context trace
+func (sm *SessionManager) SaveSessionContext(ctx context.Context, s ISession) error {
+	store := sm.getStore()
+	if store == nil {
+		return nil
+	}
+	session, ok := s.(*Session)
+	if !ok {
+		return fmt.Errorf("Session ID %s can not be saved, type %T is not supported", s.SessionId(), s)
+	}
+	if session.Version > 0 && !session.Dirty() {
+		return nil
+	}
+	if err := sm.saveToStore(ctx, store, session); err != nil {
+		return err
+	}
+	sm.publish(EventUpdated, session.ID)
+	return nil
+}
+
+// getStore returns the store of the session manager or nil if it was not set
+func (sm *SessionManager) getStore() Store {
+	if holder := sm.store.Load(); holder != nil {
+		return holder.store
+	}
+	return nil
+}
+
+// storeName returns the name of the store backend reported in the spans
+func (sm *SessionManager) storeName() string {
+	store := sm.getStore()
+	if store == nil {
+		return memoryStoreName
+	}
+	return fmt.Sprintf("%T", store)
+}
+
+// loadFromStore loads a session from the store replacing the copy held in memory
+func (sm *SessionManager) loadFromStore(ctx context.Context, store Store, sessionId string) error {
+	ctx, span := sm.startSpan(ctx, "session.store.load", sessionId)
+	defer span.End()
+
+	session, err := store.Load(ctx, sessionId)
+	if errors.Is(err, ErrSessionNotFound) {
+		span.SetAttributes(AttributeSessionHit.Bool(false))
+		sm.m.Lock()
+		delete(sm.Sessions, sessionId)
+		sm.capacity.remove(sessionId)
+		sm.m.Unlock()
+		return err
+	}
+	if err != nil {
+		sm.storeFailed(span, "load", sessionId, err)
+		return fmt.Errorf("loading session from store: %w", err)
+	}
+	span.SetAttributes(AttributeSessionHit.Bool(true))
+
+	sm.attach(session)
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	if err := sm.makeRoom(sessionId, false); err != nil {
+		return err
+	}
+	if sm.DefaultSession != nil && sm.DefaultSession.SessionId() == sessionId {
+		sm.DefaultSession = session
+	}
+	sm.Sessions[sessionId] = session
+	sm.capacity.add(sessionId, session.ExpirationTime)
+	return nil
+}
+
+// saveToStore saves a session in the store
+func (sm *SessionManager) saveToStore(ctx context.Context, store Store, s *Session) error {
+	ctx, span := sm.startSpan(ctx, "session.store.save", s.ID)
+	defer span.End()
+
+	if err := store.Save(ctx, s); err != nil {
+		sm.storeFailed(span, "save", s.ID, err)
+		return fmt.Errorf("saving session in store: %w", err)
+	}
+	return nil
+}
+
+// deleteFromStore deletes a session from the store
+func (sm *SessionManager) deleteFromStore(ctx context.Context, store Store, sessionId string) error {
+	ctx, span := sm.startSpan(ctx, "session.store.delete", sessionId)
+	defer span.End()
+
+	err := store.Delete(ctx, sessionId)
+	if errors.Is(err, ErrSessionNotFound) {
+		span.SetAttributes(AttributeSessionHit.Bool(false))
+		return err
+	}
+	if err != nil {
+		sm.storeFailed(span, "delete", sessionId, err)
+		return fmt.Errorf("deleting session from store: %w", err)
+	}
+	span.SetAttributes(AttributeSessionHit.Bool(true))
+	return nil
+}
+
+// storeFailed records a store error in the span and the logs
+//   - Version conflicts are expected with concurrent saves, they are logged at Info level
+func (sm *SessionManager) storeFailed(span trace.Span, operation string, sessionId string, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	level := slog.LevelError
+	if errors.Is(err, ErrVersionConflict) {
+		level = slog.LevelInfo
+	}
+	sm.log(level, "session store failed", sessionId, slog.String("operation", operation), slog.String("error", err.Error()))
+}
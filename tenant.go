@@ -0,0 +1,252 @@
+package sessionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TenantSeparator joins the tenant id and the session uuid in the ids of the tenant sessions
+const TenantSeparator = "~"
+
+// Tenant is a view of the session manager that only sees the sessions of one
+// tenant, its session ids are qualified with the tenant id, so a session id of
+// a tenant is never found through another tenant
+//   - The default session, TTL, quota and maximum number of sessions are set per tenant
+//   - The settings and the default session of a tenant are only kept in memory
+type Tenant struct {
+	sm               *SessionManager
+	id               string
+	m                sync.RWMutex
+	ttl              time.Duration
+	quota            *Quota
+	maxSessions      int
+	avoidExpired     bool
+	defaultSessionId string
+}
+
+// Verify that Tenant implements ISessionManager
+var _ ISessionManager = (*Tenant)(nil)
+
+// ForTenant returns the view of the sessions of a tenant, the same view is returned for the same id
+//   - The id can not be empty or contain TenantSeparator
+func (sm *SessionManager) ForTenant(id string) (*Tenant, error) {
+	if id == "" || strings.Contains(id, TenantSeparator) {
+		return nil, fmt.Errorf("invalid tenant id %q", id)
+	}
+	sm.tenantsM.Lock()
+	defer sm.tenantsM.Unlock()
+	if tenant, ok := sm.tenants[id]; ok {
+		return tenant, nil
+	}
+	if sm.tenants == nil {
+		sm.tenants = make(map[string]*Tenant)
+	}
+	tenant := &Tenant{sm: sm, id: id}
+	sm.tenants[id] = tenant
+	return tenant, nil
+}
+
+// tenantOf returns the tenant of a session if it was created through ForTenant
+func (sm *SessionManager) tenantOf(sessionId string) *Tenant {
+	id, ok := sessionTenant(sessionId)
+	if !ok {
+		return nil
+	}
+	sm.tenantsM.Lock()
+	defer sm.tenantsM.Unlock()
+	return sm.tenants[id]
+}
+
+// sessionTenant returns the tenant id qualifying a session id
+func sessionTenant(sessionId string) (string, bool) {
+	id, _, ok := strings.Cut(sessionId, TenantSeparator)
+	return id, ok
+}
+
+// ID returns the id of the tenant
+func (t *Tenant) ID() string {
+	return t.id
+}
+
+// SetTTL sets the lifetime of the new sessions of the tenant, 0 keeps the NewSession default
+func (t *Tenant) SetTTL(ttl time.Duration) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.ttl = ttl
+}
+
+// SetQuota sets the quota of the sessions of the tenant, replacing the manager quota for them
+func (t *Tenant) SetQuota(quota Quota) {
+	t.m.Lock()
+	t.quota = &quota
+	t.m.Unlock()
+	for _, session := range t.GetAllSessions() {
+		if s, ok := session.(*Session); ok {
+			s.SetQuota(quota)
+		}
+	}
+}
+
+// getQuota returns the quota of the tenant or nil if it has none
+func (t *Tenant) getQuota() *Quota {
+	t.m.RLock()
+	defer t.m.RUnlock()
+	return t.quota
+}
+
+// SetMaxSessions limits the number of sessions of the tenant held in memory,
+// CreateSession returns a *MaxSessionsError when it is reached, 0 removes the limit
+func (t *Tenant) SetMaxSessions(max int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.maxSessions = max
+}
+
+// checkRoom returns a *MaxSessionsError if the tenant is full, the manager lock must be held
+func (t *Tenant) checkRoom() error {
+	t.m.RLock()
+	max := t.maxSessions
+	t.m.RUnlock()
+	if max <= 0 || t.sm.capacity.tenantSessions(t.id) < max {
+		return nil
+	}
+	t.sm.metrics.rejected.Add(1)
+	t.sm.log(slog.LevelWarn, "session rejected, maximum number of sessions of the tenant reached", "", slog.String("tenant", t.id), slog.Int("max_sessions", max))
+	return &MaxSessionsError{MaxSessions: max, Tenant: t.id}
+}
+
+// owns returns true if the session id belongs to the tenant
+func (t *Tenant) owns(sessionId string) bool {
+	id, ok := sessionTenant(sessionId)
+	return ok && id == t.id
+}
+
+// GetSession gets a session of the tenant by session id
+func (t *Tenant) GetSession(sessionId string) (ISession, error) {
+	return t.GetSessionContext(context.Background(), sessionId)
+}
+
+// GetSessionContext gets a session of the tenant tracing the lookup as part of the context trace
+//   - Expired sessions are avoided if the tenant or the session manager avoid them
+func (t *Tenant) GetSessionContext(ctx context.Context, sessionId string) (ISession, error) {
+	if !t.owns(sessionId) {
+		return nil, notFoundError(sessionId)
+	}
+	session, err := t.sm.GetSessionContext(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	t.m.RLock()
+	avoidExpired := t.avoidExpired
+	t.m.RUnlock()
+	if avoidExpired && session.IsExpired() {
+		return nil, expiredError(sessionId)
+	}
+	return session, nil
+}
+
+// CreateSession creates a new session of the tenant
+func (t *Tenant) CreateSession() (ISession, error) {
+	return t.CreateSessionContext(context.Background())
+}
+
+// CreateSessionContext creates a new session of the tenant tracing the creation as part of the context trace
+func (t *Tenant) CreateSessionContext(ctx context.Context) (ISession, error) {
+	session := NewSession(nil)
+	session.ID = t.id + TenantSeparator + session.ID
+	t.m.RLock()
+	if t.ttl > 0 {
+		session.ExpirationTime = session.CreatedAt.Add(t.ttl)
+	}
+	t.m.RUnlock()
+	return t.sm.createSession(ctx, session, t)
+}
+
+// DestroySession destroys a session of the tenant
+func (t *Tenant) DestroySession(sessionId string) error {
+	return t.DestroySessionContext(context.Background(), sessionId)
+}
+
+// DestroySessionContext destroys a session of the tenant tracing the removal as part of the context trace
+func (t *Tenant) DestroySessionContext(ctx context.Context, sessionId string) error {
+	if !t.owns(sessionId) {
+		return notFoundError(sessionId)
+	}
+	return t.sm.DestroySessionContext(ctx, sessionId)
+}
+
+// RegenerateSession replaces a session of the tenant with a copy under a new session id of the tenant
+func (t *Tenant) RegenerateSession(sessionId string) (ISession, error) {
+	return t.RegenerateSessionContext(context.Background(), sessionId)
+}
+
+// RegenerateSessionContext replaces a session of the tenant tracing it as part of the context trace
+func (t *Tenant) RegenerateSessionContext(ctx context.Context, sessionId string) (ISession, error) {
+	if !t.owns(sessionId) {
+		return nil, notFoundError(sessionId)
+	}
+	return t.sm.RegenerateSessionContext(ctx, sessionId)
+}
+
+// SetAsDefaultSession sets the default session of the tenant
+func (t *Tenant) SetAsDefaultSession(sessionId string) error {
+	if _, err := t.GetSession(sessionId); err != nil {
+		return err
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.defaultSessionId = sessionId
+	return nil
+}
+
+// GetDefaultSession gets the default session of the tenant
+func (t *Tenant) GetDefaultSession() (ISession, error) {
+	t.m.RLock()
+	sessionId := t.defaultSessionId
+	t.m.RUnlock()
+	if sessionId == "" {
+		return nil, fmt.Errorf("default session not set")
+	}
+	return t.GetSession(sessionId)
+}
+
+// GetAllSessions returns a copy of the sessions of the tenant held in memory
+func (t *Tenant) GetAllSessions() map[string]ISession {
+	t.sm.m.RLock()
+	defer t.sm.m.RUnlock()
+	sessions := make(map[string]ISession)
+	for sessionId, session := range t.sm.Sessions {
+		if t.owns(sessionId) {
+			sessions[sessionId] = session
+		}
+	}
+	return sessions
+}
+
+// DestroyAllSessions destroys the sessions of the tenant held in memory, the
+// sessions of the other tenants are not touched
+func (t *Tenant) DestroyAllSessions() error {
+	t.m.Lock()
+	t.defaultSessionId = ""
+	t.m.Unlock()
+	for sessionId := range t.GetAllSessions() {
+		err := t.sm.DestroySession(sessionId)
+		if err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAvoidExpired sets if the tenant avoids the expired sessions, the session
+// manager setting still applies when it avoids them
+func (t *Tenant) SetAvoidExpired(avoidExpired bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.avoidExpired = avoidExpired
+}
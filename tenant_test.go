@@ -0,0 +1,150 @@
+package sessionmanager_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_ForTenant(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	for _, id := range []string{"", "a" + sessionmanager.TenantSeparator + "b"} {
+		_, err := sm.ForTenant(id)
+		assert.Error(t, err)
+	}
+	acme, err := sm.ForTenant("acme")
+	assert.NoError(t, err)
+	same, err := sm.ForTenant("acme")
+	assert.NoError(t, err)
+	assert.Same(t, acme, same)
+	other, err := sm.ForTenant("acme-labs")
+	assert.NoError(t, err)
+
+	s, err := acme.CreateSession()
+	assert.NoError(t, err)
+	_, err = acme.GetSession(s.SessionId())
+	assert.NoError(t, err)
+	_, err = sm.GetSession(s.SessionId())
+	assert.NoError(t, err)
+
+	_, err = other.GetSession(s.SessionId())
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+	assert.True(t, errors.Is(other.DestroySession(s.SessionId()), sessionmanager.ErrSessionNotFound))
+	assert.True(t, errors.Is(other.SetAsDefaultSession(s.SessionId()), sessionmanager.ErrSessionNotFound))
+	assert.Empty(t, other.GetAllSessions())
+
+	untenanted, err := sm.CreateSession()
+	assert.NoError(t, err)
+	_, err = acme.GetSession(untenanted.SessionId())
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+}
+
+func TestTenant_DestroyAllSessions(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	acme, _ := sm.ForTenant("acme")
+	globex, _ := sm.ForTenant("globex")
+
+	for i := 0; i < 3; i++ {
+		_, err := acme.CreateSession()
+		assert.NoError(t, err)
+	}
+	kept, err := globex.CreateSession()
+	assert.NoError(t, err)
+	assert.NoError(t, globex.SetAsDefaultSession(kept.SessionId()))
+	untenanted, err := sm.CreateSession()
+	assert.NoError(t, err)
+
+	defaultSession, _ := acme.CreateSession()
+	assert.NoError(t, acme.SetAsDefaultSession(defaultSession.SessionId()))
+	assert.Len(t, acme.GetAllSessions(), 4)
+
+	assert.NoError(t, acme.DestroyAllSessions())
+	assert.Empty(t, acme.GetAllSessions())
+	_, err = acme.GetDefaultSession()
+	assert.Error(t, err)
+
+	assert.Len(t, sm.GetAllSessions(), 2)
+	s, err := globex.GetDefaultSession()
+	assert.NoError(t, err)
+	assert.Equal(t, kept.SessionId(), s.SessionId())
+	_, err = sm.GetSession(untenanted.SessionId())
+	assert.NoError(t, err)
+}
+
+func TestTenant_Policies(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	sm.SetQuota(sessionmanager.Quota{MaxKeys: 10})
+	acme, _ := sm.ForTenant("acme")
+	globex, _ := sm.ForTenant("globex")
+	acme.SetTTL(time.Hour)
+	acme.SetQuota(sessionmanager.Quota{MaxKeys: 1})
+	acme.SetMaxSessions(1)
+
+	s, err := acme.CreateSession()
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), s.Metadata().ExpirationTime, time.Second)
+
+	_, err = acme.CreateSession()
+	var full *sessionmanager.MaxSessionsError
+	assert.True(t, errors.As(err, &full))
+	assert.Equal(t, "acme", full.Tenant)
+	_, err = globex.CreateSession()
+	assert.NoError(t, err)
+
+	// The manager quota does not replace the tenant quota
+	sm.SetQuota(sessionmanager.Quota{MaxKeys: 5})
+	assert.NoError(t, s.Set("a", 1))
+	var quota *sessionmanager.QuotaError
+	assert.True(t, errors.As(s.Set("b", 2), &quota))
+
+	assert.NoError(t, acme.DestroySession(s.SessionId()))
+	_, err = acme.CreateSession()
+	assert.NoError(t, err)
+}
+
+func TestTenant_Eviction(t *testing.T) {
+	for _, policy := range []sessionmanager.EvictionPolicy{sessionmanager.EvictLeastRecentlyUsed, sessionmanager.EvictSoonestToExpire} {
+		sm := sessionmanager.NewSessionManager()
+		sm.SetMaxSessions(2, policy)
+		acme, _ := sm.ForTenant("acme")
+		globex, _ := sm.ForTenant("globex")
+		kept, err := globex.CreateSession()
+		assert.NoError(t, err)
+		kept.SetExpirationTime(time.Now().Add(time.Minute))
+		evicted, err := acme.CreateSession()
+		assert.NoError(t, err)
+
+		// The least recently used and soonest to expire session belongs to globex
+		s, err := acme.CreateSession()
+		assert.NoError(t, err)
+		_, err = sm.GetSession(kept.SessionId())
+		assert.NoError(t, err)
+		_, err = sm.GetSession(evicted.SessionId())
+		assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+		_, err = sm.GetSession(s.SessionId())
+		assert.NoError(t, err)
+
+		// Sessions without tenant do not evict the sessions of the tenants
+		_, err = sm.CreateSession()
+		var full *sessionmanager.MaxSessionsError
+		assert.True(t, errors.As(err, &full))
+		assert.Len(t, sm.GetAllSessions(), 2)
+	}
+}
+
+func TestTenant_SetAvoidExpired(t *testing.T) {
+	sm := sessionmanager.NewSessionManager()
+	acme, _ := sm.ForTenant("acme")
+	s, err := acme.CreateSession()
+	assert.NoError(t, err)
+	s.SetExpirationTime(time.Now().Add(-time.Minute))
+
+	_, err = acme.GetSession(s.SessionId())
+	assert.NoError(t, err)
+	acme.SetAvoidExpired(true)
+	_, err = acme.GetSession(s.SessionId())
+	assert.True(t, errors.Is(err, sessionmanager.ErrSessionExpired))
+}
@@ -0,0 +1,53 @@
+package sessionmanager
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of the spans created by the session manager
+const tracerName = "github.com/solrac97gr/session-manager"
+
+// Attributes set in the spans created by the session manager
+const (
+	AttributeSessionIDHash  = attribute.Key("session.id_hash")
+	AttributeSessionHit     = attribute.Key("session.hit")
+	AttributeSessionExpired = attribute.Key("session.expired")
+	AttributeSessionStore   = attribute.Key("session.store")
+	AttributeSessionRevoked = attribute.Key("session.revoked")
+)
+
+// memoryStoreName is the store backend reported when sessions only live in memory
+const memoryStoreName = "memory"
+
+// tracerHolder allows to store the tracer interface in an atomic pointer
+type tracerHolder struct {
+	tracer trace.Tracer
+}
+
+// SetTracerProvider sets the OpenTelemetry tracer provider used to trace the session operations
+//   - By default the session manager uses a no-op tracer provider
+//   - Use the Context variants of the operations to make the spans children of a request span
+func (sm *SessionManager) SetTracerProvider(tp trace.TracerProvider) {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	sm.tracer.Store(&tracerHolder{tracer: tp.Tracer(tracerName)})
+}
+
+// startSpan starts a span for an operation over the session
+func (sm *SessionManager) startSpan(ctx context.Context, name string, sessionId string) (context.Context, trace.Span) {
+	holder := sm.tracer.Load()
+	if holder == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+
+	attrs := []attribute.KeyValue{AttributeSessionStore.String(sm.storeName())}
+	if sessionId != "" {
+		attrs = append(attrs, AttributeSessionIDHash.String(HashSessionID(sessionId)))
+	}
+	return holder.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
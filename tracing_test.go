@@ -0,0 +1,107 @@
+package sessionmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSessionManager_SetTracerProvider(t *testing.T) {
+	cases := map[string]struct {
+		action     func(ctx context.Context, sm *sessionmanager.SessionManager, s sessionmanager.ISession)
+		span       string
+		attributes map[attribute.Key]attribute.Value
+	}{
+		"create": {
+			action: func(ctx context.Context, sm *sessionmanager.SessionManager, s sessionmanager.ISession) {},
+			span:   "session.create",
+			attributes: map[attribute.Key]attribute.Value{
+				sessionmanager.AttributeSessionStore: attribute.StringValue("memory"),
+			},
+		},
+
+		"get hit": {
+			action: func(ctx context.Context, sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.GetSessionContext(ctx, s.SessionId())
+			},
+			span: "session.get",
+			attributes: map[attribute.Key]attribute.Value{
+				sessionmanager.AttributeSessionHit:     attribute.BoolValue(true),
+				sessionmanager.AttributeSessionExpired: attribute.BoolValue(false),
+			},
+		},
+
+		"get miss": {
+			action: func(ctx context.Context, sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.GetSessionContext(ctx, "unknown")
+			},
+			span: "session.get",
+			attributes: map[attribute.Key]attribute.Value{
+				sessionmanager.AttributeSessionHit:    attribute.BoolValue(false),
+				sessionmanager.AttributeSessionIDHash: attribute.StringValue(sessionmanager.HashSessionID("unknown")),
+			},
+		},
+
+		"get expired": {
+			action: func(ctx context.Context, sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.SetAvoidExpired(true)
+				s.SetExpirationTime(time.Now().Add(-1 * time.Minute))
+				sm.GetSessionContext(ctx, s.SessionId())
+			},
+			span: "session.get",
+			attributes: map[attribute.Key]attribute.Value{
+				sessionmanager.AttributeSessionHit:     attribute.BoolValue(false),
+				sessionmanager.AttributeSessionExpired: attribute.BoolValue(true),
+			},
+		},
+
+		"destroy": {
+			action: func(ctx context.Context, sm *sessionmanager.SessionManager, s sessionmanager.ISession) {
+				sm.DestroySessionContext(ctx, s.SessionId())
+			},
+			span: "session.destroy",
+			attributes: map[attribute.Key]attribute.Value{
+				sessionmanager.AttributeSessionHit: attribute.BoolValue(true),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			sessionManager := sessionmanager.NewSessionManager()
+			sessionManager.SetTracerProvider(provider)
+
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+			s, _ := sessionManager.CreateSessionContext(ctx)
+			tc.action(ctx, sessionManager, s)
+			parent.End()
+
+			var found bool
+			for _, span := range exporter.GetSpans() {
+				if span.Name != tc.span {
+					continue
+				}
+				found = true
+				assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+
+				attributes := map[attribute.Key]attribute.Value{}
+				for _, kv := range span.Attributes {
+					attributes[kv.Key] = kv.Value
+				}
+				for key, value := range tc.attributes {
+					assert.Equal(t, value, attributes[key], key)
+				}
+				assert.NotContains(t, attributes[sessionmanager.AttributeSessionIDHash].AsString(), s.SessionId())
+			}
+			assert.True(t, found, "span %s not recorded", tc.span)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sessionmanager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SessionTx gives access to the session data inside Update
+type SessionTx interface {
+	// Get a value from the session, including the changes of the transaction
+	Get(key string) (interface{}, error)
+	// Set a value to the session
+	Set(key string, value interface{}) error
+	// Delete a value from the session
+	Delete(key string) error
+	// Keys returns the sorted keys of the session, including the changes of the transaction
+	Keys() []string
+}
+
+// sessionTx keeps the changes of a transaction apart from the session data until it is committed
+type sessionTx struct {
+	s       *Session
+	values  map[string]interface{}
+	deleted map[string]bool
+	changes []sessionChange
+	len     int
+}
+
+// Update runs fn with the session lock held, the changes made through tx are
+// applied when fn returns nil and discarded when it returns an error
+//   - The session must only be accessed through tx inside fn, calling its methods deadlocks
+func (s *Session) Update(fn func(tx SessionTx) error) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	tx := &sessionTx{
+		s:       s,
+		values:  make(map[string]interface{}),
+		deleted: make(map[string]bool),
+		len:     len(s.Data),
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// commit records the changes together and then applies them in the order they
+// were made, none is applied if they can not be recorded, the lock must be held
+func (tx *sessionTx) commit() error {
+	if err := tx.s.notify(tx.changes...); err != nil {
+		return fmt.Errorf("committing session changes: %w", err)
+	}
+	for _, change := range tx.changes {
+		switch change.Kind {
+		case changeSet:
+			tx.s.Data[change.Key] = change.Value
+		case changeDelete:
+			delete(tx.s.Data, change.Key)
+		}
+	}
+	return nil
+}
+
+func (tx *sessionTx) Get(key string) (interface{}, error) {
+	if value, ok := tx.values[key]; ok {
+		return value, nil
+	}
+	if value, ok := tx.s.Data[key]; ok && !tx.deleted[key] {
+		return value, nil
+	}
+	return nil, errors.New("key not found")
+}
+
+func (tx *sessionTx) Set(key string, value interface{}) error {
+	if _, err := tx.Get(key); err == nil {
+		return fmt.Errorf("key %s already exists, for replace delete it first", key)
+	}
+	if err := tx.s.checkQuota(key, value, tx.len); err != nil {
+		return err
+	}
+	tx.values[key] = value
+	tx.changes = append(tx.changes, sessionChange{Kind: changeSet, Key: key, Value: value})
+	tx.len++
+	return nil
+}
+
+func (tx *sessionTx) Delete(key string) error {
+	if _, err := tx.Get(key); err != nil {
+		return err
+	}
+	delete(tx.values, key)
+	tx.deleted[key] = true
+	tx.changes = append(tx.changes, sessionChange{Kind: changeDelete, Key: key})
+	tx.len--
+	return nil
+}
+
+func (tx *sessionTx) Keys() []string {
+	keys := make([]string, 0, tx.len)
+	for key := range tx.s.Data {
+		if _, ok := tx.values[key]; !ok && !tx.deleted[key] {
+			keys = append(keys, key)
+		}
+	}
+	for key := range tx.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// namespaceTx scopes the keys of a transaction to a namespace
+type namespaceTx struct {
+	tx     SessionTx
+	prefix string
+}
+
+func (tx *namespaceTx) Get(key string) (interface{}, error) {
+	return tx.tx.Get(tx.prefix + key)
+}
+
+func (tx *namespaceTx) Set(key string, value interface{}) error {
+	return tx.tx.Set(tx.prefix+key, value)
+}
+
+func (tx *namespaceTx) Delete(key string) error {
+	return tx.tx.Delete(tx.prefix + key)
+}
+
+func (tx *namespaceTx) Keys() []string {
+	keys := []string{}
+	for _, key := range tx.tx.Keys() {
+		if strings.HasPrefix(key, tx.prefix) {
+			keys = append(keys, strings.TrimPrefix(key, tx.prefix))
+		}
+	}
+	return keys
+}
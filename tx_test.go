@@ -0,0 +1,120 @@
+package sessionmanager_test
+
+import (
+	"errors"
+	"testing"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_Update(t *testing.T) {
+	cases := map[string]struct {
+		fn       func(tx sessionmanager.SessionTx) error
+		err      bool
+		expected map[string]interface{}
+	}{
+		"committed": {
+			fn: func(tx sessionmanager.SessionTx) error {
+				item, err := tx.Get("cart")
+				if err != nil {
+					return err
+				}
+				if err := tx.Delete("cart"); err != nil {
+					return err
+				}
+				return tx.Set("wishlist", item)
+			},
+			expected: map[string]interface{}{"user": "solrac", "wishlist": "book"},
+		},
+
+		"rolled back": {
+			fn: func(tx sessionmanager.SessionTx) error {
+				tx.Delete("cart")
+				tx.Set("wishlist", "book")
+				return errors.New("out of stock")
+			},
+			err:      true,
+			expected: map[string]interface{}{"user": "solrac", "cart": "book"},
+		},
+
+		"replaced inside the transaction": {
+			fn: func(tx sessionmanager.SessionTx) error {
+				tx.Delete("user")
+				if err := tx.Set("user", "other"); err != nil {
+					return err
+				}
+				value, _ := tx.Get("user")
+				assert.Equal(t, "other", value)
+				assert.Equal(t, []string{"cart", "user"}, tx.Keys())
+				return nil
+			},
+			expected: map[string]interface{}{"user": "other", "cart": "book"},
+		},
+
+		"quota exceeded": {
+			fn: func(tx sessionmanager.SessionTx) error {
+				tx.Delete("cart")
+				if err := tx.Set("wishlist", "book"); err != nil {
+					return err
+				}
+				return tx.Set("extra", true)
+			},
+			err:      true,
+			expected: map[string]interface{}{"user": "solrac", "cart": "book"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			sm.SetQuota(sessionmanager.Quota{MaxKeys: 2})
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, s.SetMany(map[string]interface{}{"user": "solrac", "cart": "book"}))
+
+			err = s.Update(tc.fn)
+			assert.Equal(t, tc.err, err != nil)
+			assert.Equal(t, tc.expected, s.All())
+		})
+	}
+}
+
+func TestSession_Update_NotRecorded(t *testing.T) {
+	cases := map[string]struct {
+		action func(s sessionmanager.ISession) error
+	}{
+		"update": {
+			action: func(s sessionmanager.ISession) error {
+				return s.(*sessionmanager.Session).Update(func(tx sessionmanager.SessionTx) error {
+					tx.Delete("user")
+					tx.Set("cart", "book")
+					// Channels can not be encoded in the write-ahead log
+					return tx.Set("events", make(chan int))
+				})
+			},
+		},
+
+		"set many": {
+			action: func(s sessionmanager.ISession) error {
+				return s.SetMany(map[string]interface{}{"cart": "book", "events": make(chan int)})
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := sessionmanager.NewSessionManager()
+			assert.NoError(t, sm.EnableWAL(sessionmanager.WALOptions{Dir: t.TempDir()}))
+			defer sm.CloseWAL()
+			s, err := sm.CreateSession()
+			assert.NoError(t, err)
+			assert.NoError(t, s.Set("user", "solrac"))
+
+			// None of the changes is applied when they can not be recorded
+			assert.Error(t, tc.action(s))
+			assert.Equal(t, map[string]interface{}{"user": "solrac"}, s.All())
+			assert.NotContains(t, s.(*sessionmanager.Session).Changes().Set, "cart")
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package sessionmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrVersionConflict is returned by the stores when a session is saved from a
+// version older than the stored one, because another process saved it first
+var ErrVersionConflict = errors.New("session version conflict")
+
+// VersionConflict returns an ErrVersionConflict for a session saved from version,
+// the message has the HashSessionID of the session since it ends up in the logs
+//   - Stores return it when the stored session has other version or is no longer stored
+func VersionConflict(sessionId string, version uint64) error {
+	return fmt.Errorf("%w: session %s saved from stale version %d", ErrVersionConflict, HashSessionID(sessionId), version)
+}
+
+// Saved records that the session was stored with version, so its changes are not pending anymore
+//   - Stores call it after writing the session, it is not needed otherwise
+func (s *Session) Saved(version uint64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.Version = version
+	s.base = nil
+	s.expirationChanged = false
+	s.activeChanged = false
+	s.clientChanged = false
+	s.boundChanged = false
+	s.accessChanged = false
+	s.accessSavedAt = s.LastAccessedAt
+	s.state = nil
+}
+
+// merge applies the changes made to the session on top of a newer stored copy,
+// it fails if anything changed in the session was changed in the stored copy too
+//   - The fields not changed in the session, like a revocation made by another
+//     process, are taken from the stored copy
+//   - The session is only deactivated by both when it was deactivated in the stored copy
+//     and in the session, a revoke against a reactivation is a conflict
+func (s *Session) merge(stored *Session) error {
+	stored.m.RLock()
+	defer stored.m.RUnlock()
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for key, base := range s.base {
+		value, ok := stored.Data[key]
+		if ok != base.existed || (ok && !reflect.DeepEqual(value, base.value)) {
+			return mergeConflict(s.ID, "key "+key)
+		}
+	}
+	base := s.state
+	if base == nil {
+		base = &stateBase{}
+	}
+	activityChanged := stored.Active != base.active || stored.Expired != base.expired ||
+		stored.RevokedReason != base.revokedReason || !stored.RevokedAt.Equal(base.revokedAt)
+	switch {
+	case s.expirationChanged && !stored.ExpirationTime.Equal(base.expirationTime):
+		return mergeConflict(s.ID, "expiration time")
+	case s.activeChanged && activityChanged && (s.Active || stored.Active):
+		return mergeConflict(s.ID, "state")
+	case s.clientChanged && stored.Client != base.client:
+		return mergeConflict(s.ID, "client")
+	case s.boundChanged && stored.Fingerprint != base.fingerprint:
+		return mergeConflict(s.ID, "fingerprint")
+	}
+
+	data := make(map[string]interface{}, len(stored.Data))
+	for key, value := range stored.Data {
+		data[key] = value
+	}
+	for key := range s.base {
+		if value, ok := s.Data[key]; ok {
+			data[key] = value
+		} else {
+			delete(data, key)
+		}
+	}
+	s.Data = data
+	s.Version = stored.Version
+	s.CreatedAt = stored.CreatedAt
+	if !s.expirationChanged {
+		s.ExpirationTime = stored.ExpirationTime
+	}
+	switch {
+	case !s.activeChanged:
+		s.Expired, s.Active = stored.Expired, stored.Active
+		s.RevokedReason, s.RevokedAt = stored.RevokedReason, stored.RevokedAt
+	case activityChanged:
+		// Deactivated by both, a revocation is kept over an expiration
+		s.Expired = s.Expired || stored.Expired
+		if !stored.RevokedAt.IsZero() {
+			s.RevokedReason, s.RevokedAt = stored.RevokedReason, stored.RevokedAt
+		}
+	}
+	if !s.clientChanged {
+		s.Client = stored.Client
+	}
+	if !s.boundChanged {
+		s.Fingerprint = stored.Fingerprint
+	}
+	return nil
+}
+
+// mergeConflict returns an ErrVersionConflict for something changed in a session and in its stored copy
+func mergeConflict(sessionId string, what string) error {
+	return fmt.Errorf("%w: session %s %s was changed by another process", ErrVersionConflict, HashSessionID(sessionId), what)
+}
+
+// SaveSessionMerge persists a session like SaveSession, but when another process
+// saved it first the stored copy is loaded and the keys changed in s are merged
+// into it, retrying the save up to attempts times
+//   - It fails with ErrVersionConflict if both changed the same key
+func (sm *SessionManager) SaveSessionMerge(ctx context.Context, s ISession, attempts int) error {
+	store := sm.getStore()
+	if store == nil {
+		return nil
+	}
+	session, ok := s.(*Session)
+	if !ok {
+		return fmt.Errorf("Session ID %s can not be saved, type %T is not supported", s.SessionId(), s)
+	}
+	if session.Version > 0 && !session.Dirty() {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := sm.saveToStore(ctx, store, session)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrVersionConflict) || attempt >= attempts {
+			return err
+		}
+		stored, err := store.Load(ctx, session.ID)
+		if err != nil {
+			return fmt.Errorf("loading session from store: %w", err)
+		}
+		if err := session.merge(stored); err != nil {
+			return err
+		}
+	}
+	sm.publish(EventUpdated, session.ID)
+	return nil
+}
@@ -0,0 +1,223 @@
+package sessionmanager_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/solrac97gr/session-manager/boltstore"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newBoltStore builds a bolt store in a temporary file
+func newBoltStore(t *testing.T) *boltstore.Store {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sessions.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := boltstore.New(db, boltstore.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// testStores builds each store implementation of the package
+var testStores = map[string]func(t *testing.T) sessionmanager.Store{
+	"redis": func(t *testing.T) sessionmanager.Store {
+		server := newRESPServer(t, "")
+		return sessionmanager.NewRedisStore(sessionmanager.RedisStoreOptions{Addr: server.addr()})
+	},
+	"sql": func(t *testing.T) sessionmanager.Store {
+		store, _ := newSQLiteStore(t, sessionmanager.SQLStoreOptions{})
+		return store
+	},
+	"bolt": func(t *testing.T) sessionmanager.Store {
+		store := newBoltStore(t)
+		return store
+	},
+	"cache": func(t *testing.T) sessionmanager.Store {
+		store := newBoltStore(t)
+		return sessionmanager.NewCacheStore(store, sessionmanager.CacheStoreOptions{})
+	},
+}
+
+func TestStore_VersionConflict(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			s := sessionmanager.NewSession(nil)
+			assert.NoError(t, store.Save(ctx, s))
+			assert.Equal(t, uint64(1), s.Version)
+
+			first, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			second, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+
+			first.Set("first", true)
+			assert.NoError(t, store.Save(ctx, first))
+			assert.Equal(t, uint64(2), first.Version)
+
+			second.Set("second", true)
+			err = store.Save(ctx, second)
+			assert.True(t, errors.Is(err, sessionmanager.ErrVersionConflict))
+
+			// A new session can not replace a stored one
+			fresh := sessionmanager.NewSession(nil)
+			fresh.ID = s.ID
+			err = store.Save(ctx, fresh)
+			assert.True(t, errors.Is(err, sessionmanager.ErrVersionConflict))
+
+			stored, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2), stored.Version)
+			assert.Equal(t, map[string]interface{}{"first": true}, stored.All())
+		})
+	}
+}
+
+func TestStore_SaveDeleted(t *testing.T) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			s := sessionmanager.NewSession(nil)
+			assert.NoError(t, store.Save(ctx, s))
+			stale, err := store.Load(ctx, s.ID)
+			assert.NoError(t, err)
+			assert.NoError(t, store.Delete(ctx, s.ID))
+
+			// A stale copy of a deleted session does not create it again
+			stale.Set("key", "value")
+			err = store.Save(ctx, stale)
+			assert.True(t, errors.Is(err, sessionmanager.ErrVersionConflict))
+			_, err = store.Load(ctx, s.ID)
+			assert.True(t, errors.Is(err, sessionmanager.ErrSessionNotFound))
+		})
+	}
+}
+
+func TestSessionManager_SaveSessionMerge(t *testing.T) {
+	cases := map[string]struct {
+		first    func(s sessionmanager.ISession)
+		second   func(s sessionmanager.ISession)
+		err      error
+		expected map[string]interface{}
+		revoked  bool
+	}{
+		"different keys merged": {
+			first:    func(s sessionmanager.ISession) { s.Set("cart", 1) },
+			second:   func(s sessionmanager.ISession) { s.Set("theme", "dark") },
+			expected: map[string]interface{}{"user": "solrac", "cart": 1, "theme": "dark"},
+		},
+
+		"deleted key merged": {
+			first:    func(s sessionmanager.ISession) { s.Set("cart", 1) },
+			second:   func(s sessionmanager.ISession) { s.Delete("user") },
+			expected: map[string]interface{}{"cart": 1},
+		},
+
+		"same key conflict": {
+			first:    func(s sessionmanager.ISession) { s.Set("cart", 1) },
+			second:   func(s sessionmanager.ISession) { s.Set("cart", 2) },
+			err:      sessionmanager.ErrVersionConflict,
+			expected: map[string]interface{}{"user": "solrac", "cart": 1},
+		},
+
+		"revoke kept": {
+			first:    func(s sessionmanager.ISession) { s.Invalidate(sessionmanager.RevokedByAdmin) },
+			second:   func(s sessionmanager.ISession) { s.Set("cart", 1) },
+			expected: map[string]interface{}{"user": "solrac", "cart": 1},
+			revoked:  true,
+		},
+
+		"revoke against reactivate conflict": {
+			first:    func(s sessionmanager.ISession) { s.Invalidate(sessionmanager.RevokedByAdmin) },
+			second:   func(s sessionmanager.ISession) { s.Reactivate() },
+			err:      sessionmanager.ErrVersionConflict,
+			expected: map[string]interface{}{"user": "solrac"},
+			revoked:  true,
+		},
+
+		"expiration time conflict": {
+			first:    func(s sessionmanager.ISession) { s.SetExpirationTime(time.Now().Add(time.Hour)) },
+			second:   func(s sessionmanager.ISession) { s.SetExpirationTime(time.Now().Add(2 * time.Hour)) },
+			err:      sessionmanager.ErrVersionConflict,
+			expected: map[string]interface{}{"user": "solrac"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newBoltStore(t)
+			managers := make([]*sessionmanager.SessionManager, 2)
+			for i := range managers {
+				managers[i] = sessionmanager.NewSessionManager()
+				managers[i].SetStore(store)
+			}
+			created, err := managers[0].CreateSession()
+			assert.NoError(t, err)
+			created.Set("user", "solrac")
+			assert.NoError(t, managers[0].SaveSession(created))
+
+			first, err := managers[0].GetSession(created.SessionId())
+			assert.NoError(t, err)
+			second, err := managers[1].GetSession(created.SessionId())
+			assert.NoError(t, err)
+
+			tc.first(first)
+			assert.NoError(t, managers[0].SaveSession(first))
+			tc.second(second)
+			assert.True(t, errors.Is(managers[1].SaveSession(second), sessionmanager.ErrVersionConflict))
+
+			err = managers[1].SaveSessionMerge(ctx, second, 3)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err))
+			} else {
+				assert.NoError(t, err)
+			}
+			stored, err := store.Load(ctx, created.SessionId())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, stored.All())
+			assert.Equal(t, tc.revoked, stored.IsRevoked())
+			if tc.revoked {
+				_, err = managers[1].GetSession(created.SessionId())
+				assert.True(t, errors.Is(err, sessionmanager.ErrSessionRevoked))
+			}
+		})
+	}
+}
+
+func TestSessionManager_VersionConflictLog(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	store := newBoltStore(t)
+	sm := sessionmanager.NewSessionManager()
+	sm.SetStore(store)
+	sm.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	s, err := sm.CreateSession()
+	assert.NoError(t, err)
+
+	other, err := store.Load(ctx, s.SessionId())
+	assert.NoError(t, err)
+	other.Set("other", true)
+	assert.NoError(t, store.Save(ctx, other))
+
+	s.Set("key", "value")
+	err = sm.SaveSession(s)
+	assert.True(t, errors.Is(err, sessionmanager.ErrVersionConflict))
+	assert.NotContains(t, err.Error(), s.SessionId())
+	assert.Contains(t, buf.String(), "level=INFO msg=\"session store failed\"")
+	assert.NotContains(t, buf.String(), s.SessionId())
+}
@@ -0,0 +1,460 @@
+package sessionmanager
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy defines when the write-ahead log is flushed to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the log after every entry, no recorded change is lost on a crash
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the log every WALOptions.SyncInterval
+	SyncInterval
+	// SyncNever leaves flushing the log to the operating system
+	SyncNever
+)
+
+// Files stored in the write-ahead log directory
+const (
+	walSnapshotFile = "sessions.snapshot"
+	walFilePrefix   = "sessions.wal."
+)
+
+// ErrWALNotEnabled is returned when using the write-ahead log before enabling it
+var ErrWALNotEnabled = errors.New("write-ahead log not enabled")
+
+// WALOptions configures the write-ahead log of a session manager
+type WALOptions struct {
+	// Dir is the directory where the log and its snapshot are stored
+	Dir string
+	// SyncPolicy defines when the log is flushed to disk, by default SyncAlways
+	SyncPolicy SyncPolicy
+	// SyncInterval is the flush period of SyncInterval, by default 1 second
+	SyncInterval time.Duration
+	// CompactInterval is the period to compact the log into a snapshot, 0 disables it
+	CompactInterval time.Duration
+}
+
+// walOp identifies the operation recorded by a log entry
+type walOp uint8
+
+const (
+	walCreate walOp = iota + 1
+	walDestroy
+	walDestroyAll
+	walDefault
+	walChange
+	walChanges
+	walRegenerate
+)
+
+// walEntry is a mutation recorded in the write-ahead log
+type walEntry struct {
+	Op        walOp
+	SessionId string
+	Session   *SessionRecord
+	Change    sessionChange
+	// Changes are recorded together by the transactions and the batch operations
+	Changes []sessionChange
+}
+
+// wal is an append-only log split in generations, a generation is
+// removed once a snapshot containing its entries is written
+type wal struct {
+	opts     WALOptions
+	m        sync.Mutex
+	compactM sync.Mutex
+	file     *os.File
+	gen      uint64
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+// EnableWAL enables the write-ahead log of the session manager
+//   - The sessions recorded in opts.Dir are replayed into the session manager
+//   - Every mutation is appended to the log before being applied: creating, destroying
+//     and setting the default session, and the Set, Delete and SetExpirationTime of the sessions
+//   - The log is compacted into a snapshot when enabled and every opts.CompactInterval
+func (sm *SessionManager) EnableWAL(opts WALOptions) error {
+	if sm.wal.Load() != nil {
+		return errors.New("write-ahead log already enabled")
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return err
+	}
+
+	if err := sm.replayWAL(opts.Dir); err != nil {
+		return err
+	}
+
+	generations, err := walGenerations(opts.Dir)
+	if err != nil {
+		return err
+	}
+	w := &wal{opts: opts, stop: make(chan struct{})}
+	if len(generations) > 0 {
+		w.gen = generations[len(generations)-1]
+	}
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	sm.wal.Store(w)
+
+	if err := sm.CompactWAL(); err != nil {
+		sm.CloseWAL()
+		return err
+	}
+
+	if opts.SyncPolicy == SyncInterval {
+		w.every(opts.SyncInterval, func() {
+			w.m.Lock()
+			defer w.m.Unlock()
+			if err := w.file.Sync(); err != nil {
+				sm.log(slog.LevelError, "write-ahead log sync failed", "", slog.String("error", err.Error()))
+			}
+		})
+	}
+	if opts.CompactInterval > 0 {
+		w.every(opts.CompactInterval, func() {
+			if err := sm.CompactWAL(); err != nil {
+				sm.log(slog.LevelError, "write-ahead log compaction failed", "", slog.String("error", err.Error()))
+			}
+		})
+	}
+	return nil
+}
+
+// CompactWAL writes a snapshot of the sessions and removes the log entries included in it
+func (sm *SessionManager) CompactWAL() error {
+	w := sm.wal.Load()
+	if w == nil {
+		return ErrWALNotEnabled
+	}
+	w.compactM.Lock()
+	defer w.compactM.Unlock()
+
+	// The entries appended after the rotation can also be in the snapshot,
+	// replaying them again is harmless because every entry overwrites the state
+	w.m.Lock()
+	compacted := w.gen
+	err := w.rotate()
+	w.m.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(w.opts.Dir, walSnapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := sm.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(w.opts.Dir, walSnapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(w.opts.Dir); err != nil {
+		return err
+	}
+
+	generations, err := walGenerations(w.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for _, gen := range generations {
+		if gen <= compacted {
+			if err := os.Remove(walPath(w.opts.Dir, gen)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CloseWAL flushes and closes the write-ahead log, the session manager stops recording mutations
+func (sm *SessionManager) CloseWAL() error {
+	w := sm.wal.Swap(nil)
+	if w == nil {
+		return ErrWALNotEnabled
+	}
+	close(w.stop)
+	w.done.Wait()
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// appendWAL records an entry in the write-ahead log if it is enabled
+func (sm *SessionManager) appendWAL(entry walEntry) error {
+	w := sm.wal.Load()
+	if w == nil {
+		return nil
+	}
+	if err := w.append(entry); err != nil {
+		sm.log(slog.LevelError, "write-ahead log append failed", entry.SessionId, slog.String("error", err.Error()))
+		return fmt.Errorf("recording session change in write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// replayWAL loads the snapshot and the log generations stored in dir
+func (sm *SessionManager) replayWAL(dir string) error {
+	f, err := os.Open(filepath.Join(dir, walSnapshotFile))
+	if err == nil {
+		err = sm.Restore(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	generations, err := walGenerations(dir)
+	if err != nil {
+		return err
+	}
+
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	if sm.Sessions == nil {
+		sm.Sessions = make(map[string]ISession)
+	}
+	for _, gen := range generations {
+		if err := sm.replayWALFile(walPath(dir, gen)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayWALFile applies the entries of a log generation, a truncated
+// or corrupted entry stops the replay of the generation
+func (sm *SessionManager) replayWALFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if !errors.Is(err, io.EOF) {
+				sm.log(slog.LevelWarn, "write-ahead log truncated", "", slog.String("file", path))
+			}
+			return nil
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		data, err := io.ReadAll(io.LimitReader(r, int64(length)))
+		if err != nil || uint32(len(data)) != length || crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+			sm.log(slog.LevelWarn, "write-ahead log corrupted", "", slog.String("file", path))
+			return nil
+		}
+
+		var entry walEntry
+		if err := decodeGob(data, &entry); err != nil {
+			return fmt.Errorf("decoding write-ahead log entry: %w", err)
+		}
+		sm.applyWALEntry(entry)
+	}
+}
+
+// applyWALEntry applies a log entry to the sessions, the manager lock must be held
+func (sm *SessionManager) applyWALEntry(entry walEntry) {
+	switch entry.Op {
+	case walCreate:
+		if entry.Session == nil {
+			return
+		}
+		session := entry.Session.Session()
+		sm.attach(session)
+		sm.Sessions[entry.SessionId] = session
+		sm.capacity.add(entry.SessionId, session.ExpirationTime)
+	case walRegenerate:
+		if entry.Session == nil {
+			return
+		}
+		sm.regenerated(entry.SessionId, entry.Session.Session())
+	case walDestroy:
+		delete(sm.Sessions, entry.SessionId)
+		sm.capacity.remove(entry.SessionId)
+	case walDestroyAll:
+		sm.Sessions = make(map[string]ISession)
+		sm.capacity.reset()
+	case walDefault:
+		if session, ok := sm.Sessions[entry.SessionId]; ok {
+			sm.DefaultSession = session
+		}
+	case walChange, walChanges:
+		session, ok := sm.Sessions[entry.SessionId].(*Session)
+		if !ok {
+			return
+		}
+		changes := entry.Changes
+		if entry.Op == walChange {
+			changes = []sessionChange{entry.Change}
+		}
+		session.m.Lock()
+		defer session.m.Unlock()
+		for _, change := range changes {
+			sm.applyChange(session, change)
+		}
+	}
+}
+
+// applyChange applies a logged change to a session, the session lock must be held
+func (sm *SessionManager) applyChange(session *Session, change sessionChange) {
+	switch change.Kind {
+	case changeSet:
+		session.Data[change.Key] = change.Value
+	case changeDelete:
+		delete(session.Data, change.Key)
+	case changeExpirationTime:
+		session.ExpirationTime = change.ExpirationTime
+		sm.capacity.expirationChanged(session.ID, change.ExpirationTime)
+	case changeClear:
+		session.Data = make(map[string]interface{})
+	case changeRevoke:
+		session.Active = false
+		session.RevokedReason = change.Reason
+		session.RevokedAt = change.RevokedAt
+	case changeReactivate:
+		session.Active = true
+		session.Expired = false
+		session.RevokedReason = ""
+		session.RevokedAt = time.Time{}
+	case changeClient:
+		session.Client = change.Client
+	case changeBind:
+		session.Fingerprint = change.Fingerprint
+	}
+}
+
+// append writes an entry framed with its length and checksum
+func (w *wal) append(entry walEntry) error {
+	data, err := encodeGob(entry)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(frame[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(data))
+	frame = append(frame, data...)
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	if _, err := w.file.Write(frame); err != nil {
+		return err
+	}
+	if w.opts.SyncPolicy == SyncAlways {
+		return w.file.Sync()
+	}
+	return nil
+}
+
+// rotate starts appending to a new generation, the lock must be held
+func (w *wal) rotate() error {
+	f, err := os.OpenFile(walPath(w.opts.Dir, w.gen+1), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if w.file != nil {
+		if err := w.file.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		w.file.Close()
+	}
+	w.file = f
+	w.gen++
+	return syncDir(w.opts.Dir)
+}
+
+// every runs fn periodically until the log is closed
+func (w *wal) every(interval time.Duration, fn func()) {
+	w.done.Add(1)
+	go func() {
+		defer w.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+// walPath returns the path of a log generation
+func walPath(dir string, gen uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d", walFilePrefix, gen))
+}
+
+// walGenerations returns the log generations stored in dir in ascending order
+func walGenerations(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var generations []uint64
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), walFilePrefix) {
+			continue
+		}
+		gen, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), walFilePrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		generations = append(generations, gen)
+	}
+	sort.Slice(generations, func(i, j int) bool { return generations[i] < generations[j] })
+	return generations, nil
+}
+
+// syncDir flushes the directory entries so created and renamed files survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,172 @@
+package sessionmanager_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sessionmanager "github.com/solrac97gr/session-manager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_EnableWAL(t *testing.T) {
+	expiration := time.Now().Add(2 * time.Hour).Round(0)
+
+	cases := map[string]struct {
+		opts    sessionmanager.WALOptions
+		compact bool
+		corrupt bool
+	}{
+		"sync always": {
+			opts: sessionmanager.WALOptions{SyncPolicy: sessionmanager.SyncAlways},
+		},
+
+		"sync interval": {
+			opts: sessionmanager.WALOptions{SyncPolicy: sessionmanager.SyncInterval, SyncInterval: time.Millisecond},
+		},
+
+		"sync never": {
+			opts: sessionmanager.WALOptions{SyncPolicy: sessionmanager.SyncNever},
+		},
+
+		"compacted": {
+			opts:    sessionmanager.WALOptions{SyncPolicy: sessionmanager.SyncAlways},
+			compact: true,
+		},
+
+		"torn last entry": {
+			opts:    sessionmanager.WALOptions{SyncPolicy: sessionmanager.SyncAlways},
+			corrupt: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tc.opts.Dir = t.TempDir()
+
+			sessionManager := sessionmanager.NewSessionManager()
+			assert.NoError(t, sessionManager.EnableWAL(tc.opts))
+
+			kept, _ := sessionManager.CreateSession()
+			kept.SetMany(map[string]interface{}{"draft": true, "step": 1})
+			kept.Clear()
+			kept.Set("user", "solrac")
+			kept.Set("age", 20)
+			kept.Set("cart", []string{"apple"})
+			kept.Delete("cart")
+			kept.SetExpirationTime(expiration)
+			assert.NoError(t, sessionManager.SetAsDefaultSession(kept.SessionId()))
+
+			destroyed, _ := sessionManager.CreateSession()
+			destroyed.Set("user", "other")
+			assert.NoError(t, sessionManager.DestroySession(destroyed.SessionId()))
+
+			if tc.compact {
+				assert.NoError(t, sessionManager.CompactWAL())
+			}
+			if tc.corrupt {
+				kept.Set("lost", true)
+			}
+			assert.NoError(t, sessionManager.CloseWAL())
+
+			if tc.corrupt {
+				files, _ := filepath.Glob(filepath.Join(tc.opts.Dir, "sessions.wal.*"))
+				last := files[len(files)-1]
+				info, _ := os.Stat(last)
+				assert.NoError(t, os.Truncate(last, info.Size()-3))
+			}
+
+			recovered := sessionmanager.NewSessionManager()
+			assert.NoError(t, recovered.EnableWAL(tc.opts))
+			defer recovered.CloseWAL()
+
+			assert.Len(t, recovered.Sessions, 1)
+			s, err := recovered.GetSession(kept.SessionId())
+			assert.NoError(t, err)
+			expectedData := map[string]interface{}{"user": "solrac", "age": 20}
+			assert.Equal(t, expectedData, s.(*sessionmanager.Session).Data)
+			assert.True(t, expiration.Equal(s.(*sessionmanager.Session).ExpirationTime))
+
+			defaultSession, err := recovered.GetDefaultSession()
+			assert.NoError(t, err)
+			assert.Same(t, s, defaultSession)
+
+			// Mutations after recovering are recorded in the log too
+			s.Set("after", "recovery")
+			assert.NoError(t, recovered.CloseWAL())
+			again := sessionmanager.NewSessionManager()
+			assert.NoError(t, again.EnableWAL(tc.opts))
+			defer again.CloseWAL()
+			s, _ = again.GetSession(kept.SessionId())
+			value, err := s.Get("after")
+			assert.NoError(t, err)
+			assert.Equal(t, "recovery", value)
+		})
+	}
+}
+
+func TestSessionManager_CompactWAL(t *testing.T) {
+	cases := map[string]struct {
+		enabled bool
+		err     error
+	}{
+		"enabled": {
+			enabled: true,
+		},
+
+		"not enabled": {
+			err: sessionmanager.ErrWALNotEnabled,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sessionManager := sessionmanager.NewSessionManager()
+			if tc.enabled {
+				assert.NoError(t, sessionManager.EnableWAL(sessionmanager.WALOptions{Dir: dir}))
+				defer sessionManager.CloseWAL()
+				for i := 0; i < 10; i++ {
+					s, _ := sessionManager.CreateSession()
+					s.Set("index", i)
+				}
+			}
+
+			err := sessionManager.CompactWAL()
+			assert.Equal(t, tc.err, err)
+			if tc.err != nil {
+				return
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "sessions.wal.*"))
+			assert.Len(t, files, 1)
+			info, _ := os.Stat(files[0])
+			assert.Zero(t, info.Size())
+			assert.FileExists(t, filepath.Join(dir, "sessions.snapshot"))
+		})
+	}
+}
+
+func TestSessionManager_EnableWAL_Regenerate(t *testing.T) {
+	opts := sessionmanager.WALOptions{Dir: t.TempDir(), SyncPolicy: sessionmanager.SyncAlways}
+	sm := sessionmanager.NewSessionManager()
+	assert.NoError(t, sm.EnableWAL(opts))
+	old, _ := sm.CreateSession()
+	old.Set("user", "solrac")
+	assert.NoError(t, sm.SetAsDefaultSession(old.SessionId()))
+	s, err := sm.RegenerateSession(old.SessionId())
+	assert.NoError(t, err)
+	assert.NoError(t, sm.CloseWAL())
+
+	recovered := sessionmanager.NewSessionManager()
+	assert.NoError(t, recovered.EnableWAL(opts))
+	defer recovered.CloseWAL()
+	assert.Len(t, recovered.Sessions, 1)
+	_, err = recovered.GetSession(old.SessionId())
+	assert.Error(t, err)
+	defaultSession, err := recovered.GetDefaultSession()
+	assert.NoError(t, err)
+	assert.Equal(t, s.SessionId(), defaultSession.SessionId())
+	assert.Equal(t, map[string]interface{}{"user": "solrac"}, defaultSession.All())
+}